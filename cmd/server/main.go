@@ -6,22 +6,33 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
 
 	"ads-txt-service/internal/cache"
 	"ads-txt-service/internal/config"
+	"ads-txt-service/internal/crawler"
+	"ads-txt-service/internal/domains"
+	"ads-txt-service/internal/events"
 	"ads-txt-service/internal/fetcher"
 	"ads-txt-service/internal/handler"
+	"ads-txt-service/internal/index"
 	"ads-txt-service/internal/logger"
 	"ads-txt-service/internal/parser"
+	"ads-txt-service/internal/sellers"
+	"ads-txt-service/internal/storage"
+	"ads-txt-service/internal/webhook"
 )
 
 type Application struct {
 	cfg          *config.Config
 	log          *logger.Logger
 	cache        cache.Cache
+	srv          *handler.Server
+	crawler      *crawler.Crawler
+	webhooks     *webhook.Dispatcher
 	httpServer   *http.Server
 	shutdownWait sync.WaitGroup
 }
@@ -43,26 +54,75 @@ func NewApplication() (*Application, error) {
 		return nil, fmt.Errorf("failed to init cache: %w", err)
 	}
 
-	adsCache := cache.NewAdsCache(cacheBackend)
+	adsCache := cache.NewAdsCache(cacheBackend, cfg.CacheMaxStale)
 
-	ft := fetcher.NewFetcher(cfg.HttpClientTO)
+	ft := fetcher.NewFetcher(cfg.HttpClientTO, cfg.FetchMaxBodySize)
 
 	pr := parser.NewParser()
 
-	srv := handler.NewServer(cfg, adsCache, log, ft, pr)
+	verifier := sellers.NewVerifier(ft, cacheBackend, cfg.SellersCacheTTL, log)
 
+	history, err := storage.NewDiskStore(filepath.Join(cfg.DataDir, "history"), cfg.HistoryMaxSnapshots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init snapshot store: %w", err)
+	}
+
+	raw, err := storage.NewDiskRawStore(filepath.Join(cfg.DataDir, "raw"), cfg.HistoryMaxSnapshots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init raw store: %w", err)
+	}
+
+	tracked, err := domains.NewStore(filepath.Join(cfg.DataDir, "domains.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to init domain store: %w", err)
+	}
+
+	hooks, err := webhook.NewRegistry(filepath.Join(cfg.DataDir, "webhooks.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to init webhook registry: %w", err)
+	}
+	dispatcher := webhook.NewDispatcher(hooks, cfg.WebhookTimeout, cfg.WebhookMaxAttempts, cfg.WebhookBackoff,
+		filepath.Join(cfg.DataDir, "webhooks-dead-letter.jsonl"), cfg.WebhookAllowPrivateNetworks, log)
+
+	broker := events.NewBroker(cfg.EventsBacklog, log)
+
+	sources := crawler.MultiSource{tracked}
+	if cfg.CrawlDomainsFile != "" {
+		sources = append(sources, crawler.NewFileSource(cfg.CrawlDomainsFile))
+	}
+	cr := crawler.New(sources, cfg.CrawlInterval, cfg.CrawlJitter, cfg.CrawlWorkers, log)
+
+	srv := handler.NewServer(cfg, adsCache, log, ft, pr,
+		handler.WithSellersVerifier(verifier),
+		handler.WithRecordIndex(index.New()),
+		handler.WithSnapshotStore(history),
+		handler.WithRawStore(raw),
+		handler.WithDomainStore(tracked),
+		handler.WithCrawlerStatus(cr),
+		handler.WithWebhookRegistry(hooks),
+		handler.WithChangeNotifier(dispatcher),
+		handler.WithEventStream(broker),
+	)
+
+	// WriteTimeout leaves room for a file and then its SUBDOMAIN files to
+	// time out with the timeout error still reaching the client.
 	httpServer := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      srv.Router(),
 		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		WriteTimeout: 2*cfg.HttpClientTO + 5*time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
+	// Open event streams never go idle on their own.
+	httpServer.RegisterOnShutdown(broker.Close)
 
 	return &Application{
 		cfg:        cfg,
 		log:        log,
 		cache:      cacheBackend,
+		srv:        srv,
+		crawler:    cr,
+		webhooks:   dispatcher,
 		httpServer: httpServer,
 	}, nil
 }
@@ -77,6 +137,18 @@ func (a *Application) Run(ctx context.Context) error {
 		}
 	}()
 
+	a.shutdownWait.Add(1)
+	go func() {
+		defer a.shutdownWait.Done()
+		a.crawler.Run(ctx, a.srv)
+	}()
+
+	a.shutdownWait.Add(1)
+	go func() {
+		defer a.shutdownWait.Done()
+		a.webhooks.Run(ctx)
+	}()
+
 	<-ctx.Done()
 	a.log.Info("Shutdown signal received")
 	return a.Shutdown()
@@ -117,4 +189,4 @@ func main() {
 		app.log.Errorf("Application error: %v", err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
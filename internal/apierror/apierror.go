@@ -0,0 +1,66 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the ID assigned to each request. It is set on the
+// response before any handler runs, so error responses can echo it.
+const RequestIDHeader = "X-Request-ID"
+
+// Codes for errors raised by the API itself. Fetch failures use the
+// fetcher's failure kinds as their code instead.
+const (
+	CodeMissingDomain        = "missing_domain"
+	CodeInvalidDomain        = "invalid_domain"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeInvalidBody          = "invalid_body"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeRateLimited          = "rate_limited"
+	CodeRouteNotFound        = "route_not_found"
+	CodeSnapshotNotFound     = "snapshot_not_found"
+	CodeDomainNotFound       = "domain_not_found"
+	CodeWebhookNotFound      = "webhook_not_found"
+	CodeRawNotFound          = "raw_not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeInternal             = "internal_error"
+)
+
+// New builds an error response for the given code.
+func New(code, message string) *models.ErrorResponse {
+	return &models.ErrorResponse{Code: code, Message: message}
+}
+
+// Write sends e as JSON with the given status. The request ID is filled in
+// from the response headers and RetryAfter is mirrored in the Retry-After
+// header.
+func Write(w http.ResponseWriter, status int, e *models.ErrorResponse) {
+	if e.RequestID == "" {
+		e.RequestID = w.Header().Get(RequestIDHeader)
+	}
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		logger.L().Errorw("Failed to encode error response", zap.Error(err))
+	}
+}
+
+// NotFound and MethodNotAllowed replace the router's plain text defaults.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, http.StatusNotFound, New(CodeRouteNotFound, "no route for "+r.URL.Path))
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, http.StatusMethodNotAllowed, New(CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path))
+}
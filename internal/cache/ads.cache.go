@@ -1,37 +1,94 @@
- package cache
+package cache
 
 import (
-    "context"
-    "encoding/json"
-    "time"
-    "ads-txt-service/internal/models"
+	"context"
+	"encoding/json"
+	"time"
+
+	"ads-txt-service/internal/models"
 )
 
+// adsEntry wraps a cached response with the point after which it is stale.
+// The backend keeps the entry for maxStale longer than that, which is the
+// hard expiry.
+type adsEntry struct {
+	Response   *models.AdsResponse `json:"response"`
+	FreshUntil time.Time           `json:"fresh_until"`
+}
+
 type AdsCache struct {
-	cache Cache
+	cache    Cache
+	maxStale time.Duration
 }
 
-func NewAdsCache(c Cache) *AdsCache {
-	return &AdsCache{cache: c}
+// NewAdsCache returns an AdsCache that keeps serving entries for up to
+// maxStale after their TTL, flagged as stale.
+func NewAdsCache(c Cache, maxStale time.Duration) *AdsCache {
+	return &AdsCache{cache: c, maxStale: maxStale}
+}
+
+// AdsKey returns the cache key for a domain's ads.txt or app-ads.txt. Plain
+// ads.txt entries keep using the bare domain as their key.
+func AdsKey(file, domain string) string {
+	if file == models.FileAdsTxt {
+		return domain
+	}
+	return file + ":" + domain
 }
 
+// GetAds returns the cached response with Stale and AgeSeconds filled in.
 func (a *AdsCache) GetAds(ctx context.Context, key string) (*models.AdsResponse, bool) {
 	b, err := a.cache.Get(ctx, key)
 	if err != nil || b == nil {
 		return nil, false
 	}
 
-	var resp models.AdsResponse
-	if err := json.Unmarshal(b, &resp); err != nil {
+	var e adsEntry
+	if err := json.Unmarshal(b, &e); err != nil || e.Response == nil {
 		return nil, false
 	}
-	return &resp, true
+
+	now := time.Now()
+	e.Response.Stale = now.After(e.FreshUntil)
+	e.Response.AgeSeconds = int64(now.Sub(e.Response.Timestamp).Seconds())
+	return e.Response, true
 }
 
+// SetAds stores resp as fresh for ttl and retrievable as stale for the
+// configured max-stale period after that.
 func (a *AdsCache) SetAds(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
-	b, err := json.Marshal(resp)
+	b, err := json.Marshal(adsEntry{Response: resp, FreshUntil: time.Now().Add(ttl).UTC()})
 	if err != nil {
 		return err
 	}
-	return a.cache.Set(ctx, key, b, ttl)
-}
\ No newline at end of file
+	return a.cache.Set(ctx, key, b, ttl+a.maxStale)
+}
+
+// failureKey keeps negative entries apart from the responses they stand in for.
+func failureKey(key string) string {
+	return "failure:" + key
+}
+
+// GetFailure returns a cached fetch failure for key, if any.
+func (a *AdsCache) GetFailure(ctx context.Context, key string) (*models.FetchFailure, bool) {
+	b, err := a.cache.Get(ctx, failureKey(key))
+	if err != nil || b == nil {
+		return nil, false
+	}
+
+	var f models.FetchFailure
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, false
+	}
+	return &f, true
+}
+
+// SetFailure caches a fetch failure for key. Failures use their own, usually
+// much shorter, ttl than successful responses.
+func (a *AdsCache) SetFailure(ctx context.Context, key string, f *models.FetchFailure, ttl time.Duration) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return a.cache.Set(ctx, failureKey(key), b, ttl)
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ads-txt-service/internal/models"
+)
+
+func TestAdsCache_Staleness(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryCache(0, 0, time.Minute)
+	defer backend.Close()
+	ac := NewAdsCache(backend, 50*time.Millisecond)
+
+	resp := &models.AdsResponse{Domain: "test.com", Timestamp: time.Now().UTC()}
+	ac.SetAds(ctx, "test.com", resp, 20*time.Millisecond)
+
+	got, ok := ac.GetAds(ctx, "test.com")
+	if !ok || got.Stale {
+		t.Fatalf("expected a fresh entry, got ok=%v %+v", ok, got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	got, ok = ac.GetAds(ctx, "test.com")
+	if !ok || !got.Stale {
+		t.Fatalf("expected a stale entry past the soft expiry, got ok=%v %+v", ok, got)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := ac.GetAds(ctx, "test.com"); ok {
+		t.Errorf("expected the entry to be gone past the hard expiry")
+	}
+}
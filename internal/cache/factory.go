@@ -2,13 +2,29 @@ package cache
 
 import (
 	"fmt"
+	"time"
+
 	"ads-txt-service/internal/config"
 )
 
+// memoryJanitorInterval is how often the memory backend sweeps expired entries.
+const memoryJanitorInterval = time.Minute
+
 func InitCache(cfg *config.Config) (Cache, error) {
 	switch cfg.CacheBackend {
 	case "redis":
 		return NewRedisCache(cfg.RedisAddr, cfg.RedisPassword)
+	case "memory":
+		return NewMemoryCache(cfg.MemoryCacheMaxEntries, cfg.MemoryCacheMaxBytes, memoryJanitorInterval), nil
+	case "file":
+		return NewFileCache(cfg.CacheDir)
+	case "tiered":
+		l2, err := NewRedisCache(cfg.RedisAddr, cfg.RedisPassword)
+		if err != nil {
+			return nil, err
+		}
+		l1 := NewMemoryCache(cfg.L1CacheMaxEntries, cfg.L1CacheMaxBytes, memoryJanitorInterval)
+		return NewTieredCache(l1, l2, cfg.L1CacheTTL), nil
 	default:
 		return nil, fmt.Errorf("unsupported cache backend: %s", cfg.CacheBackend)
 	}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ads-txt-service/internal/fsutil"
+)
+
+const fileCacheExt = ".json"
+
+// fileEntry is the on-disk format of a FileCache entry. The key is kept so
+// that entries stay identifiable when inspecting the directory.
+type fileEntry struct {
+	Key       string    `json:"key"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Data      []byte    `json:"data"`
+}
+
+func (e *fileEntry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// FileCache stores each entry as a JSON file under dir, named by the SHA-256
+// of its key. Writes are atomic, so entries survive restarts and a crash
+// never leaves a truncated entry behind.
+type FileCache struct {
+	dir string
+}
+
+// NewFileCache creates dir if needed and removes entries that expired while
+// the service was down, along with temp files of interrupted writes.
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	fc := &FileCache{dir: dir}
+	if err := fc.cleanup(); err != nil {
+		return nil, fmt.Errorf("failed to clean cache dir: %w", err)
+	}
+	return fc, nil
+}
+
+func (f *FileCache) Get(_ context.Context, key string) ([]byte, error) {
+	path := f.path(key)
+	e, err := readFileEntry(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file cache GET failed: %w", err)
+	}
+	if e.Key != key {
+		return nil, nil
+	}
+	if e.expired(time.Now()) {
+		os.Remove(path)
+		return nil, nil
+	}
+	return e.Data, nil
+}
+
+func (f *FileCache) Set(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	e := fileEntry{Key: key, Data: data}
+	if ttl > 0 {
+		e.ExpiresAt = time.Now().Add(ttl).UTC()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("file cache SET failed: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(f.path(key), b, 0o644); err != nil {
+		return fmt.Errorf("file cache SET failed: %w", err)
+	}
+	return nil
+}
+
+func (f *FileCache) Del(_ context.Context, key string) error {
+	if err := os.Remove(f.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("file cache DEL failed: %w", err)
+	}
+	return nil
+}
+
+func (f *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+fileCacheExt)
+}
+
+func (f *FileCache) cleanup() error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, de := range entries {
+		if de.IsDir() {
+			continue
+		}
+		path := filepath.Join(f.dir, de.Name())
+		if strings.HasPrefix(de.Name(), fsutil.TempPrefix) {
+			os.Remove(path)
+			continue
+		}
+		if !strings.HasSuffix(de.Name(), fileCacheExt) {
+			continue
+		}
+		// Unreadable entries are as good as expired.
+		if e, err := readFileEntry(path); err != nil || e.expired(now) {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+func readFileEntry(path string) (*fileEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var e fileEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
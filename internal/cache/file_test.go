@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fc, err := NewFileCache(dir)
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+
+	fc.Set(ctx, "keep", []byte("1"), time.Hour)
+	fc.Set(ctx, "expire", []byte("2"), 10*time.Millisecond)
+	if b, _ := fc.Get(ctx, "keep"); string(b) != "1" {
+		t.Errorf("expected %q, got %q", "1", b)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if b, _ := fc.Get(ctx, "expire"); b != nil {
+		t.Errorf("expected expired entry to be gone, got %q", b)
+	}
+
+	fc.Set(ctx, "expire", []byte("2"), 10*time.Millisecond)
+	os.WriteFile(filepath.Join(dir, ".tmp-leftover"), []byte("x"), 0o644)
+	time.Sleep(20 * time.Millisecond)
+
+	// A restart should keep live entries and drop expired ones and leftovers.
+	fc, err = NewFileCache(dir)
+	if err != nil {
+		t.Fatalf("NewFileCache: %v", err)
+	}
+	if b, _ := fc.Get(ctx, "keep"); string(b) != "1" {
+		t.Errorf("expected entry to survive restart, got %q", b)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expected only the live entry on disk after cleanup, found %d files", len(files))
+	}
+
+	fc.Del(ctx, "keep")
+	if b, _ := fc.Get(ctx, "keep"); b != nil {
+		t.Errorf("expected deleted entry to be gone, got %q", b)
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// MemoryCache is an in-process Cache with per-entry TTLs. It is bounded by
+// entry count and total payload size; when either limit is exceeded the
+// least recently used entries are evicted. A background janitor removes
+// expired entries until Close is called. A zero limit means unbounded.
+type MemoryCache struct {
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List
+	maxEntries int
+	maxBytes   int64
+	size       int64
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewMemoryCache(maxEntries int, maxBytes int64, janitorInterval time.Duration) *MemoryCache {
+	m := &MemoryCache{
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		stop:       make(chan struct{}),
+	}
+	go m.janitor(janitorInterval)
+	return m
+}
+
+func (m *MemoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if e.expired(time.Now()) {
+		m.removeLocked(el)
+		return nil, nil
+	}
+	m.lru.MoveToFront(el)
+
+	out := make([]byte, len(e.data))
+	copy(out, e.data)
+	return out, nil
+}
+
+func (m *MemoryCache) Set(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	e := &memoryEntry{key: key, data: make([]byte, len(data))}
+	copy(e.data, data)
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.removeLocked(el)
+	}
+	if m.maxBytes > 0 && int64(len(e.data)) > m.maxBytes {
+		// Storing it would evict everything else and still not fit.
+		return nil
+	}
+
+	m.items[key] = m.lru.PushFront(e)
+	m.size += int64(len(e.data))
+
+	for m.overLimitLocked() {
+		m.removeLocked(m.lru.Back())
+	}
+	return nil
+}
+
+func (m *MemoryCache) Del(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.removeLocked(el)
+	}
+	return nil
+}
+
+// Len returns the number of entries currently held, including expired ones
+// the janitor has not collected yet.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+func (m *MemoryCache) Close() error {
+	m.closeOnce.Do(func() { close(m.stop) })
+	return nil
+}
+
+func (m *MemoryCache) overLimitLocked() bool {
+	if m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		return true
+	}
+	return m.maxBytes > 0 && m.size > m.maxBytes
+}
+
+func (m *MemoryCache) removeLocked(el *list.Element) {
+	e := m.lru.Remove(el).(*memoryEntry)
+	delete(m.items, e.key)
+	m.size -= int64(len(e.data))
+}
+
+func (m *MemoryCache) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.deleteExpired()
+		}
+	}
+}
+
+func (m *MemoryCache) deleteExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for el := m.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if el.Value.(*memoryEntry).expired(now) {
+			m.removeLocked(el)
+		}
+		el = prev
+	}
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("GetSetDel", func(t *testing.T) {
+		m := NewMemoryCache(0, 0, time.Minute)
+		defer m.Close()
+
+		m.Set(ctx, "a", []byte("1"), time.Minute)
+		if b, _ := m.Get(ctx, "a"); string(b) != "1" {
+			t.Errorf("expected %q, got %q", "1", b)
+		}
+		m.Del(ctx, "a")
+		if b, _ := m.Get(ctx, "a"); b != nil {
+			t.Errorf("expected deleted entry to be gone, got %q", b)
+		}
+	})
+
+	t.Run("TTLExpiry", func(t *testing.T) {
+		m := NewMemoryCache(0, 0, 10*time.Millisecond)
+		defer m.Close()
+
+		m.Set(ctx, "a", []byte("1"), 20*time.Millisecond)
+		m.Set(ctx, "b", []byte("2"), 0)
+		time.Sleep(50 * time.Millisecond)
+		if m.Len() != 1 {
+			t.Errorf("expected janitor to remove the expired entry, have %d entries", m.Len())
+		}
+		if b, _ := m.Get(ctx, "a"); b != nil {
+			t.Errorf("expected expired entry to be gone, got %q", b)
+		}
+		if b, _ := m.Get(ctx, "b"); string(b) != "2" {
+			t.Errorf("expected entry without TTL to survive, got %q", b)
+		}
+	})
+
+	t.Run("EvictsLeastRecentlyUsedByCount", func(t *testing.T) {
+		m := NewMemoryCache(2, 0, time.Minute)
+		defer m.Close()
+
+		m.Set(ctx, "a", []byte("1"), 0)
+		m.Set(ctx, "b", []byte("2"), 0)
+		m.Get(ctx, "a")
+		m.Set(ctx, "c", []byte("3"), 0)
+
+		if b, _ := m.Get(ctx, "b"); b != nil {
+			t.Errorf("expected least recently used entry to be evicted")
+		}
+		if b, _ := m.Get(ctx, "a"); b == nil {
+			t.Errorf("expected recently used entry to be kept")
+		}
+	})
+
+	t.Run("EvictsByBytes", func(t *testing.T) {
+		m := NewMemoryCache(0, 10, time.Minute)
+		defer m.Close()
+
+		m.Set(ctx, "a", []byte("12345"), 0)
+		m.Set(ctx, "b", []byte("12345"), 0)
+		m.Set(ctx, "c", []byte("123"), 0)
+		if m.Len() != 2 {
+			t.Errorf("expected 2 entries within the byte limit, have %d", m.Len())
+		}
+		m.Set(ctx, "d", []byte("12345678901"), 0)
+		if b, _ := m.Get(ctx, "d"); b != nil {
+			t.Errorf("expected oversized entry not to be stored")
+		}
+	})
+}
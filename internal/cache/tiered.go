@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TieredCache keeps a small in-process L1 in front of a shared L2 backend.
+// Reads are served from L1 when possible and populate it on an L2 hit;
+// writes and deletes go through to both tiers. L1 entries live at most
+// l1TTL, which bounds how long a node can serve data another node has
+// already replaced in L2.
+type TieredCache struct {
+	l1    *MemoryCache
+	l2    Cache
+	l1TTL time.Duration
+}
+
+func NewTieredCache(l1 *MemoryCache, l2 Cache, l1TTL time.Duration) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2, l1TTL: l1TTL}
+}
+
+func (t *TieredCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if b, _ := t.l1.Get(ctx, key); b != nil {
+		return b, nil
+	}
+
+	b, err := t.l2.Get(ctx, key)
+	if err != nil || b == nil {
+		return b, err
+	}
+	t.l1.Set(ctx, key, b, t.l1TTL)
+	return b, nil
+}
+
+func (t *TieredCache) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if err := t.l2.Set(ctx, key, data, ttl); err != nil {
+		t.l1.Del(ctx, key)
+		return err
+	}
+	l1TTL := t.l1TTL
+	if ttl > 0 && ttl < l1TTL {
+		l1TTL = ttl
+	}
+	return t.l1.Set(ctx, key, data, l1TTL)
+}
+
+func (t *TieredCache) Del(ctx context.Context, key string) error {
+	t.l1.Del(ctx, key)
+	return t.l2.Del(ctx, key)
+}
+
+func (t *TieredCache) Close() error {
+	errs := []error{t.l1.Close()}
+	if closer, ok := t.l2.(interface{ Close() error }); ok {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
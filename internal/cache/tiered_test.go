@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTieredCache(t *testing.T) {
+	ctx := context.Background()
+	l1 := NewMemoryCache(10, 16, time.Minute)
+	l2 := NewMemoryCache(0, 0, time.Minute)
+	tc := NewTieredCache(l1, l2, time.Minute)
+	defer tc.Close()
+
+	l2.Set(ctx, "a", []byte("1"), time.Hour)
+	if b, _ := tc.Get(ctx, "a"); string(b) != "1" {
+		t.Fatalf("expected read-through from L2, got %q", b)
+	}
+	if b, _ := l1.Get(ctx, "a"); string(b) != "1" {
+		t.Errorf("expected L2 hit to populate L1, got %q", b)
+	}
+
+	tc.Set(ctx, "b", []byte("2"), time.Hour)
+	if b, _ := l2.Get(ctx, "b"); string(b) != "2" {
+		t.Errorf("expected Set to write through to L2, got %q", b)
+	}
+	if b, _ := l1.Get(ctx, "b"); string(b) != "2" {
+		t.Errorf("expected Set to populate L1, got %q", b)
+	}
+
+	tc.Del(ctx, "b")
+	if b, _ := l1.Get(ctx, "b"); b != nil {
+		t.Errorf("expected Del to clear L1")
+	}
+	if b, _ := l2.Get(ctx, "b"); b != nil {
+		t.Errorf("expected Del to clear L2")
+	}
+
+	// Values over L1's byte limit are served from L2 alone.
+	big := []byte("a value too large for the L1 tier")
+	tc.Set(ctx, "c", big, time.Hour)
+	if b, _ := tc.Get(ctx, "c"); string(b) != string(big) {
+		t.Errorf("expected a large value from L2, got %q", b)
+	}
+	if b, _ := l1.Get(ctx, "c"); b != nil {
+		t.Errorf("expected L1 to skip a value over its byte limit")
+	}
+}
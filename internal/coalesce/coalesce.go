@@ -0,0 +1,67 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+)
+
+type call[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Group deduplicates concurrent work that shares a key: while a call for a
+// key is in flight, later callers wait for its result instead of starting
+// their own. The zero value is ready to use.
+type Group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+// Do runs fn for key unless a call for key is already in flight, and
+// returns its result. shared reports whether the result went to more than
+// one caller.
+//
+// fn runs with a context detached from ctx, so a caller giving up does not
+// cancel the work for everyone else waiting on it; that caller alone gets
+// ctx.Err(). fn is responsible for bounding its own run time.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(context.Context) (T, error)) (v T, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call[T])
+	}
+	c, inflight := g.calls[key]
+	if !inflight {
+		c = &call[T]{done: make(chan struct{})}
+		g.calls[key] = c
+		go g.run(context.WithoutCancel(ctx), key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err, inflight
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err(), inflight
+	}
+}
+
+// InFlight reports whether a call for key is currently running.
+func (g *Group[T]) InFlight(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
+
+func (g *Group[T]) run(ctx context.Context, key string, c *call[T], fn func(context.Context) (T, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn(ctx)
+}
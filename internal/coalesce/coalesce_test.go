@@ -0,0 +1,93 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_Do(t *testing.T) {
+	t.Run("DeduplicatesConcurrentCalls", func(t *testing.T) {
+		var g Group[int]
+		var runs atomic.Int32
+		release := make(chan struct{})
+
+		var wg sync.WaitGroup
+		results := make(chan int, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err, _ := g.Do(context.Background(), "key", func(ctx context.Context) (int, error) {
+					runs.Add(1)
+					<-release
+					return 42, nil
+				})
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				results <- v
+			}()
+		}
+
+		for !g.InFlight("key") {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		close(results)
+
+		if runs.Load() != 1 {
+			t.Errorf("expected fn to run once, ran %d times", runs.Load())
+		}
+		for v := range results {
+			if v != 42 {
+				t.Errorf("expected shared result 42, got %d", v)
+			}
+		}
+		if g.InFlight("key") {
+			t.Errorf("expected call to be cleared after completion")
+		}
+	})
+
+	t.Run("CallerCancellationDoesNotFailOthers", func(t *testing.T) {
+		var g Group[int]
+		release := make(chan struct{})
+		fn := func(ctx context.Context) (int, error) {
+			select {
+			case <-release:
+				return 7, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelled := make(chan error, 1)
+		go func() {
+			_, err, _ := g.Do(ctx, "key", fn)
+			cancelled <- err
+		}()
+		for !g.InFlight("key") {
+			time.Sleep(time.Millisecond)
+		}
+
+		other := make(chan int, 1)
+		go func() {
+			v, _, _ := g.Do(context.Background(), "key", fn)
+			other <- v
+		}()
+
+		cancel()
+		if err := <-cancelled; err != context.Canceled {
+			t.Errorf("expected cancelled caller to get context.Canceled, got %v", err)
+		}
+		close(release)
+		if v := <-other; v != 7 {
+			t.Errorf("expected remaining caller to get the result, got %d", v)
+		}
+	})
+}
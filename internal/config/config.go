@@ -8,30 +8,79 @@ import (
 )
 
 type Config struct {
-	Port          int           `json:"port"`            
-	CacheBackend  string        `json:"cache_backend"`  
-	CacheTTL      time.Duration `json:"cache_ttl"`     
-	LimiterMaxReq  int           `json:"limiter_max_req"`
-	LimmiterTTL  int        	`json:"limiter_ttl"`
-	LogLevel      string        `json:"log_level"`      
-	HttpClientTO  time.Duration `json:"http_client_to"` 
-	RedisAddr     string        `json:"redis_addr"`     
-	RedisPassword string        `json:"redis_password"` 
+	Port                  int           `json:"port"`
+	CacheBackend          string        `json:"cache_backend"`
+	CacheTTL              time.Duration `json:"cache_ttl"`
+	CacheMaxStale         time.Duration `json:"cache_max_stale"`
+	NegativeCacheTTL      time.Duration `json:"negative_cache_ttl"`
+	LimiterMaxReq         int           `json:"limiter_max_req"`
+	LimmiterTTL           int           `json:"limiter_ttl"`
+	LogLevel              string        `json:"log_level"`
+	HttpClientTO          time.Duration `json:"http_client_to"`
+	FetchMaxBodySize      int64         `json:"fetch_max_body_size"`
+	RedisAddr             string        `json:"redis_addr"`
+	RedisPassword         string        `json:"redis_password"`
+	SellersCacheTTL       time.Duration `json:"sellers_cache_ttl"`
+	MemoryCacheMaxEntries int           `json:"memory_cache_max_entries"`
+	MemoryCacheMaxBytes   int64         `json:"memory_cache_max_bytes"`
+	CacheDir              string        `json:"cache_dir"`
+	L1CacheTTL            time.Duration `json:"l1_cache_ttl"`
+	L1CacheMaxEntries     int           `json:"l1_cache_max_entries"`
+	L1CacheMaxBytes       int64         `json:"l1_cache_max_bytes"`
+	BatchMaxDomains       int           `json:"batch_max_domains"`
+	BatchConcurrency      int           `json:"batch_concurrency"`
+	DataDir               string        `json:"data_dir"`
+	HistoryMaxSnapshots   int           `json:"history_max_snapshots"`
+	CrawlDomainsFile      string        `json:"crawl_domains_file"`
+	CrawlInterval         time.Duration `json:"crawl_interval"`
+	CrawlJitter           time.Duration `json:"crawl_jitter"`
+	CrawlWorkers          int           `json:"crawl_workers"`
+	WebhookTimeout        time.Duration `json:"webhook_timeout"`
+	WebhookMaxAttempts    int           `json:"webhook_max_attempts"`
+	WebhookBackoff        time.Duration `json:"webhook_backoff"`
+	EventsBacklog         int           `json:"events_backlog"`
+	EventsHeartbeat       time.Duration `json:"events_heartbeat"`
+
+	// WebhookAllowPrivateNetworks lets webhooks target loopback, link-local
+	// and private addresses, for deployments whose receivers are internal.
+	WebhookAllowPrivateNetworks bool `json:"webhook_allow_private_networks"`
 }
 
 var DefaultConfig = Config{
-	Port:          8080,
-	CacheBackend:  "redis",
-	CacheTTL:      300 * time.Second,
-	LimmiterTTL:  5,
-	LimiterMaxReq: 5,
-	LogLevel:      "info",
-	HttpClientTO:  10 * time.Second,
-	RedisAddr:     "localhost:6379",
-	RedisPassword: "",
+	Port:                  8080,
+	CacheBackend:          "redis",
+	CacheTTL:              300 * time.Second,
+	CacheMaxStale:         time.Hour,
+	NegativeCacheTTL:      60 * time.Second,
+	LimmiterTTL:           5,
+	LimiterMaxReq:         5,
+	LogLevel:              "info",
+	HttpClientTO:          10 * time.Second,
+	FetchMaxBodySize:      10 << 20,
+	RedisAddr:             "localhost:6379",
+	RedisPassword:         "",
+	SellersCacheTTL:       24 * time.Hour,
+	MemoryCacheMaxEntries: 10000,
+	MemoryCacheMaxBytes:   256 << 20,
+	CacheDir:              "data/cache",
+	L1CacheTTL:            10 * time.Second,
+	L1CacheMaxEntries:     1000,
+	L1CacheMaxBytes:       32 << 20,
+	BatchMaxDomains:       1000,
+	BatchConcurrency:      16,
+	DataDir:               "data",
+	HistoryMaxSnapshots:   200,
+	CrawlDomainsFile:      "",
+	CrawlInterval:         time.Hour,
+	CrawlJitter:           5 * time.Minute,
+	CrawlWorkers:          4,
+	WebhookTimeout:        10 * time.Second,
+	WebhookMaxAttempts:    5,
+	WebhookBackoff:        2 * time.Second,
+	EventsBacklog:         1000,
+	EventsHeartbeat:       15 * time.Second,
 }
 
-
 func LoadFromEnv() (*Config, error) {
 	cfg := DefaultConfig
 
@@ -71,6 +120,18 @@ func loadEnvVars(cfg *Config) error {
 		cfg.CacheTTL = time.Duration(ttl) * time.Second
 	}
 
+	if maxStaleStr := os.Getenv("CACHE_MAX_STALE_SECONDS"); maxStaleStr != "" {
+		maxStale, err := strconv.Atoi(maxStaleStr)
+		addError(err)
+		cfg.CacheMaxStale = time.Duration(maxStale) * time.Second
+	}
+
+	if ttlStr := os.Getenv("NEGATIVE_CACHE_TTL_SECONDS"); ttlStr != "" {
+		ttl, err := strconv.Atoi(ttlStr)
+		addError(err)
+		cfg.NegativeCacheTTL = time.Duration(ttl) * time.Second
+	}
+
 	if maxReqLimiterStr := os.Getenv("LIMITER_MAX_REQ"); maxReqLimiterStr != "" {
 		maxReq, err := strconv.Atoi(maxReqLimiterStr)
 		addError(err)
@@ -93,12 +154,138 @@ func loadEnvVars(cfg *Config) error {
 		cfg.HttpClientTO = time.Duration(timeout) * time.Second
 	}
 
+	if maxBodyStr := os.Getenv("FETCH_MAX_BODY_BYTES"); maxBodyStr != "" {
+		maxBody, err := strconv.ParseInt(maxBodyStr, 10, 64)
+		addError(err)
+		cfg.FetchMaxBodySize = maxBody
+	}
+
+	if cacheDir := os.Getenv("CACHE_DIR"); cacheDir != "" {
+		cfg.CacheDir = cacheDir
+	}
+
+	if ttlStr := os.Getenv("L1_CACHE_TTL_SECONDS"); ttlStr != "" {
+		ttl, err := strconv.Atoi(ttlStr)
+		addError(err)
+		cfg.L1CacheTTL = time.Duration(ttl) * time.Second
+	}
+
+	if maxEntriesStr := os.Getenv("L1_CACHE_MAX_ENTRIES"); maxEntriesStr != "" {
+		maxEntries, err := strconv.Atoi(maxEntriesStr)
+		addError(err)
+		cfg.L1CacheMaxEntries = maxEntries
+	}
+
+	if maxBytesStr := os.Getenv("L1_CACHE_MAX_BYTES"); maxBytesStr != "" {
+		maxBytes, err := strconv.ParseInt(maxBytesStr, 10, 64)
+		addError(err)
+		cfg.L1CacheMaxBytes = maxBytes
+	}
+
 	if redisAddr := os.Getenv("REDIS_ADDR"); redisAddr != "" {
 		cfg.RedisAddr = redisAddr
 	}
 
 	cfg.RedisPassword = os.Getenv("REDIS_PASSWORD")
 
+	if ttlStr := os.Getenv("SELLERS_CACHE_TTL_SECONDS"); ttlStr != "" {
+		ttl, err := strconv.Atoi(ttlStr)
+		addError(err)
+		cfg.SellersCacheTTL = time.Duration(ttl) * time.Second
+	}
+
+	if maxEntriesStr := os.Getenv("MEMORY_CACHE_MAX_ENTRIES"); maxEntriesStr != "" {
+		maxEntries, err := strconv.Atoi(maxEntriesStr)
+		addError(err)
+		cfg.MemoryCacheMaxEntries = maxEntries
+	}
+
+	if maxBytesStr := os.Getenv("MEMORY_CACHE_MAX_BYTES"); maxBytesStr != "" {
+		maxBytes, err := strconv.ParseInt(maxBytesStr, 10, 64)
+		addError(err)
+		cfg.MemoryCacheMaxBytes = maxBytes
+	}
+
+	if maxDomainsStr := os.Getenv("BATCH_MAX_DOMAINS"); maxDomainsStr != "" {
+		maxDomains, err := strconv.Atoi(maxDomainsStr)
+		addError(err)
+		cfg.BatchMaxDomains = maxDomains
+	}
+
+	if concurrencyStr := os.Getenv("BATCH_CONCURRENCY"); concurrencyStr != "" {
+		concurrency, err := strconv.Atoi(concurrencyStr)
+		addError(err)
+		cfg.BatchConcurrency = concurrency
+	}
+
+	if dataDir := os.Getenv("DATA_DIR"); dataDir != "" {
+		cfg.DataDir = dataDir
+	}
+
+	if maxSnapshotsStr := os.Getenv("HISTORY_MAX_SNAPSHOTS"); maxSnapshotsStr != "" {
+		maxSnapshots, err := strconv.Atoi(maxSnapshotsStr)
+		addError(err)
+		cfg.HistoryMaxSnapshots = maxSnapshots
+	}
+
+	if domainsFile := os.Getenv("CRAWL_DOMAINS_FILE"); domainsFile != "" {
+		cfg.CrawlDomainsFile = domainsFile
+	}
+
+	if intervalStr := os.Getenv("CRAWL_INTERVAL_SECONDS"); intervalStr != "" {
+		interval, err := strconv.Atoi(intervalStr)
+		addError(err)
+		cfg.CrawlInterval = time.Duration(interval) * time.Second
+	}
+
+	if jitterStr := os.Getenv("CRAWL_JITTER_SECONDS"); jitterStr != "" {
+		jitter, err := strconv.Atoi(jitterStr)
+		addError(err)
+		cfg.CrawlJitter = time.Duration(jitter) * time.Second
+	}
+
+	if workersStr := os.Getenv("CRAWL_WORKERS"); workersStr != "" {
+		workers, err := strconv.Atoi(workersStr)
+		addError(err)
+		cfg.CrawlWorkers = workers
+	}
+
+	if timeoutStr := os.Getenv("WEBHOOK_TIMEOUT_SECONDS"); timeoutStr != "" {
+		timeout, err := strconv.Atoi(timeoutStr)
+		addError(err)
+		cfg.WebhookTimeout = time.Duration(timeout) * time.Second
+	}
+
+	if maxAttemptsStr := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); maxAttemptsStr != "" {
+		maxAttempts, err := strconv.Atoi(maxAttemptsStr)
+		addError(err)
+		cfg.WebhookMaxAttempts = maxAttempts
+	}
+
+	if backoffStr := os.Getenv("WEBHOOK_BACKOFF_SECONDS"); backoffStr != "" {
+		backoff, err := strconv.Atoi(backoffStr)
+		addError(err)
+		cfg.WebhookBackoff = time.Duration(backoff) * time.Second
+	}
+
+	if allowStr := os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS"); allowStr != "" {
+		allow, err := strconv.ParseBool(allowStr)
+		addError(err)
+		cfg.WebhookAllowPrivateNetworks = allow
+	}
+
+	if backlogStr := os.Getenv("EVENTS_BACKLOG"); backlogStr != "" {
+		backlog, err := strconv.Atoi(backlogStr)
+		addError(err)
+		cfg.EventsBacklog = backlog
+	}
+
+	if heartbeatStr := os.Getenv("EVENTS_HEARTBEAT_SECONDS"); heartbeatStr != "" {
+		heartbeat, err := strconv.Atoi(heartbeatStr)
+		addError(err)
+		cfg.EventsHeartbeat = time.Duration(heartbeat) * time.Second
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("errors loading environment variables: %v", errs)
 	}
@@ -113,14 +300,24 @@ func (c *Config) Validate() error {
 		errs = append(errs, fmt.Errorf("port %d is invalid, must be between 1 and 65535", c.Port))
 	}
 
-	if c.CacheBackend != "redis" && c.CacheBackend != "memory" {
-		errs = append(errs, fmt.Errorf("cache backend %q is unsupported, must be 'redis' or 'memory'", c.CacheBackend))
+	switch c.CacheBackend {
+	case "redis", "memory", "file", "tiered":
+	default:
+		errs = append(errs, fmt.Errorf("cache backend %q is unsupported, must be 'redis', 'memory', 'file' or 'tiered'", c.CacheBackend))
 	}
 
 	if c.CacheTTL <= 0 {
 		errs = append(errs, fmt.Errorf("cache TTL %v is invalid, must be positive", c.CacheTTL))
 	}
 
+	if c.CacheMaxStale < 0 {
+		errs = append(errs, fmt.Errorf("cache max stale %v is invalid, must not be negative", c.CacheMaxStale))
+	}
+
+	if c.NegativeCacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("negative cache TTL %v is invalid, must not be negative", c.NegativeCacheTTL))
+	}
+
 	if c.LimiterMaxReq <= 0 {
 		errs = append(errs, fmt.Errorf("max requests per second %d is invalid, must be positive", c.LimiterMaxReq))
 	}
@@ -137,8 +334,88 @@ func (c *Config) Validate() error {
 		errs = append(errs, fmt.Errorf("HTTP client timeout %v is invalid, must be positive", c.HttpClientTO))
 	}
 
-	if c.CacheBackend == "redis" && c.RedisAddr == "" {
-		errs = append(errs, fmt.Errorf("redis address is empty but required for redis cache backend"))
+	if c.FetchMaxBodySize <= 0 {
+		errs = append(errs, fmt.Errorf("fetch max body size %d is invalid, must be positive", c.FetchMaxBodySize))
+	}
+
+	if c.SellersCacheTTL <= 0 {
+		errs = append(errs, fmt.Errorf("sellers cache TTL %v is invalid, must be positive", c.SellersCacheTTL))
+	}
+
+	if c.MemoryCacheMaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("memory cache max entries %d is invalid, must not be negative", c.MemoryCacheMaxEntries))
+	}
+
+	if c.MemoryCacheMaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("memory cache max bytes %d is invalid, must not be negative", c.MemoryCacheMaxBytes))
+	}
+
+	if (c.CacheBackend == "redis" || c.CacheBackend == "tiered") && c.RedisAddr == "" {
+		errs = append(errs, fmt.Errorf("redis address is empty but required for %s cache backend", c.CacheBackend))
+	}
+
+	if c.CacheBackend == "tiered" && c.L1CacheTTL <= 0 {
+		errs = append(errs, fmt.Errorf("L1 cache TTL %v is invalid, must be positive", c.L1CacheTTL))
+	}
+
+	if c.CacheBackend == "tiered" && c.L1CacheMaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("L1 cache max entries %d is invalid, must be positive", c.L1CacheMaxEntries))
+	}
+
+	if c.CacheBackend == "tiered" && c.L1CacheMaxBytes <= 0 {
+		errs = append(errs, fmt.Errorf("L1 cache max bytes %d is invalid, must be positive", c.L1CacheMaxBytes))
+	}
+
+	if c.CacheBackend == "file" && c.CacheDir == "" {
+		errs = append(errs, fmt.Errorf("cache dir is empty but required for file cache backend"))
+	}
+
+	if c.BatchMaxDomains <= 0 {
+		errs = append(errs, fmt.Errorf("batch max domains %d is invalid, must be positive", c.BatchMaxDomains))
+	}
+
+	if c.BatchConcurrency <= 0 {
+		errs = append(errs, fmt.Errorf("batch concurrency %d is invalid, must be positive", c.BatchConcurrency))
+	}
+
+	if c.DataDir == "" {
+		errs = append(errs, fmt.Errorf("data dir is empty but required"))
+	}
+
+	if c.HistoryMaxSnapshots < 0 {
+		errs = append(errs, fmt.Errorf("history max snapshots %d is invalid, must not be negative", c.HistoryMaxSnapshots))
+	}
+
+	if c.CrawlInterval <= 0 {
+		errs = append(errs, fmt.Errorf("crawl interval %v is invalid, must be positive", c.CrawlInterval))
+	}
+
+	if c.CrawlJitter < 0 {
+		errs = append(errs, fmt.Errorf("crawl jitter %v is invalid, must not be negative", c.CrawlJitter))
+	}
+
+	if c.CrawlWorkers <= 0 {
+		errs = append(errs, fmt.Errorf("crawl workers %d is invalid, must be positive", c.CrawlWorkers))
+	}
+
+	if c.WebhookTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("webhook timeout %v is invalid, must be positive", c.WebhookTimeout))
+	}
+
+	if c.WebhookMaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("webhook max attempts %d is invalid, must be positive", c.WebhookMaxAttempts))
+	}
+
+	if c.WebhookBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("webhook backoff %v is invalid, must be positive", c.WebhookBackoff))
+	}
+
+	if c.EventsBacklog < 0 {
+		errs = append(errs, fmt.Errorf("events backlog %d is invalid, must not be negative", c.EventsBacklog))
+	}
+
+	if c.EventsHeartbeat <= 0 {
+		errs = append(errs, fmt.Errorf("events heartbeat %v is invalid, must be positive", c.EventsHeartbeat))
 	}
 
 	if len(errs) > 0 {
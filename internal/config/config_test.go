@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadFromEnv_Defaults(t *testing.T) {
+	// Unset variables and empty ones are treated alike.
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		t.Setenv(name, "")
+	}
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv with no environment: %v", err)
+	}
+	if *cfg != DefaultConfig {
+		t.Errorf("expected the defaults, got %+v", cfg)
+	}
+}
@@ -0,0 +1,299 @@
+package crawler
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// tick is how often due targets are queued.
+	tick = time.Second
+	// reloadEvery is how often the target list is re-read from the source.
+	reloadEvery = 30 * time.Second
+)
+
+// Refresher fetches a domain's file and stores the result wherever the
+// service keeps it.
+type Refresher interface {
+	Refresh(ctx context.Context, domain, file string) error
+}
+
+type entry struct {
+	target    Target
+	nextRun   time.Time
+	lastRun   time.Time
+	lastErr   string
+	successes int64
+	failures  int64
+	queued    bool
+	removed   bool
+}
+
+// Crawler periodically refreshes every target of its source with a bounded
+// pool of workers, so API requests for those domains are served from a warm
+// cache. Each target is refreshed every interval plus a random jitter, and
+// new targets start at a random point within the first jitter window, so
+// refreshes of a large list spread out instead of arriving in bursts.
+type Crawler struct {
+	src      Source
+	interval time.Duration
+	jitter   time.Duration
+	workers  int
+	log      *logger.Logger
+
+	tick        time.Duration
+	reloadEvery time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]*entry
+	queueDepth int
+	inProgress int
+	running    bool
+	lastRun    time.Time
+	srcErr     string
+	successes  int64
+	failures   int64
+}
+
+func New(src Source, interval, jitter time.Duration, workers int, log *logger.Logger) *Crawler {
+	return &Crawler{
+		src:         src,
+		interval:    interval,
+		jitter:      jitter,
+		workers:     workers,
+		log:         log,
+		tick:        tick,
+		reloadEvery: reloadEvery,
+		entries:     make(map[string]*entry),
+	}
+}
+
+// Run crawls until ctx is cancelled and returns once in-flight refreshes
+// have finished.
+func (c *Crawler) Run(ctx context.Context, r Refresher) {
+	c.setRunning(true)
+	defer c.setRunning(false)
+	c.log.Infow("Crawler started", "workers", c.workers, "interval", c.interval)
+
+	jobs := make(chan *entry)
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				c.crawl(ctx, r, e)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(c.tick)
+	defer ticker.Stop()
+
+	c.reload(ctx)
+	lastReload := time.Now()
+	var queue []*entry
+	for {
+		// Sending is only enabled while there is work, so the loop keeps
+		// ticking while every worker is busy.
+		var send chan<- *entry
+		var next *entry
+		if len(queue) > 0 {
+			send, next = jobs, queue[0]
+		}
+
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			c.log.Infow("Crawler stopped")
+			return
+		case now := <-ticker.C:
+			if now.Sub(lastReload) >= c.reloadEvery {
+				c.reload(ctx)
+				lastReload = now
+			}
+			queue = append(queue, c.due(now)...)
+		case send <- next:
+			queue = queue[1:]
+		}
+		c.setQueueDepth(len(queue))
+	}
+}
+
+// Status returns a snapshot of the crawler's progress, targets sorted by
+// domain.
+func (c *Crawler) Status() *models.CrawlerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := &models.CrawlerStatus{
+		Running:         c.running,
+		Workers:         c.workers,
+		IntervalSeconds: int64(c.interval / time.Second),
+		JitterSeconds:   int64(c.jitter / time.Second),
+		Targets:         len(c.entries),
+		QueueDepth:      c.queueDepth,
+		InProgress:      c.inProgress,
+		LastRun:         timePtr(c.lastRun),
+		LastSourceError: c.srcErr,
+		Successes:       c.successes,
+		Failures:        c.failures,
+		Domains:         make([]*models.CrawlTargetStatus, 0, len(c.entries)),
+	}
+	for _, e := range c.entries {
+		out.Domains = append(out.Domains, &models.CrawlTargetStatus{
+			Domain:    e.target.Domain,
+			Type:      e.target.Type,
+			LastRun:   timePtr(e.lastRun),
+			NextRun:   e.nextRun,
+			LastError: e.lastErr,
+			Successes: e.successes,
+			Failures:  e.failures,
+		})
+	}
+	slices.SortFunc(out.Domains, func(a, b *models.CrawlTargetStatus) int {
+		if n := strings.Compare(a.Domain, b.Domain); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Type, b.Type)
+	})
+	return out
+}
+
+// reload syncs the entries with the source. On error the previous list is
+// kept, so a broken edit of the domains file does not stop the crawl.
+func (c *Crawler) reload(ctx context.Context) {
+	targets, err := c.src.Targets(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.log.Warnw("Failed to load crawl targets", zap.Error(err))
+		c.srcErr = err.Error()
+		return
+	}
+	c.srcErr = ""
+
+	now := time.Now()
+	seen := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		key := t.key()
+		seen[key] = true
+		if e, ok := c.entries[key]; ok {
+			if t.Interval != e.target.Interval && !e.lastRun.IsZero() {
+				e.nextRun = e.lastRun.Add(c.wait(t))
+			}
+			e.target = t
+			continue
+		}
+		c.entries[key] = &entry{target: t, nextRun: now.Add(c.randJitter())}
+	}
+	for key, e := range c.entries {
+		if !seen[key] {
+			// A queued entry is dropped by its worker.
+			e.removed = true
+			delete(c.entries, key)
+		}
+	}
+}
+
+// due marks and returns the entries whose next run has passed.
+func (c *Crawler) due(now time.Time) []*entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []*entry
+	for _, e := range c.entries {
+		if !e.queued && !now.Before(e.nextRun) {
+			e.queued = true
+			out = append(out, e)
+		}
+	}
+	slices.SortFunc(out, func(a, b *entry) int { return a.nextRun.Compare(b.nextRun) })
+	return out
+}
+
+func (c *Crawler) crawl(ctx context.Context, r Refresher, e *entry) {
+	c.mu.Lock()
+	if e.removed {
+		c.mu.Unlock()
+		return
+	}
+	t := e.target
+	c.inProgress++
+	c.mu.Unlock()
+
+	err := r.Refresh(ctx, t.Domain, t.Type)
+	if ctx.Err() != nil {
+		// Shutting down; the result says nothing about the domain.
+		c.mu.Lock()
+		c.inProgress--
+		e.queued = false
+		c.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inProgress--
+	c.lastRun = now
+	e.queued = false
+	e.lastRun = now
+	e.nextRun = now.Add(c.wait(e.target))
+	if err != nil {
+		c.log.Warnw("Crawl failed", zap.Error(err), "domain", t.Domain, "file", t.Type)
+		e.lastErr = err.Error()
+		e.failures++
+		c.failures++
+		return
+	}
+	e.lastErr = ""
+	e.successes++
+	c.successes++
+}
+
+func (c *Crawler) wait(t Target) time.Duration {
+	interval := t.Interval
+	if interval <= 0 {
+		interval = c.interval
+	}
+	return interval + c.randJitter()
+}
+
+func (c *Crawler) randJitter() time.Duration {
+	if c.jitter <= 0 {
+		return 0
+	}
+	return rand.N(c.jitter)
+}
+
+func (c *Crawler) setRunning(running bool) {
+	c.mu.Lock()
+	c.running = running
+	c.mu.Unlock()
+}
+
+func (c *Crawler) setQueueDepth(n int) {
+	c.mu.Lock()
+	c.queueDepth = n
+	c.mu.Unlock()
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
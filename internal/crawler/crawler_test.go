@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+)
+
+type staticSource struct {
+	mu      sync.Mutex
+	targets []Target
+}
+
+func (s *staticSource) Targets(ctx context.Context) ([]Target, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.targets, nil
+}
+
+func (s *staticSource) set(targets ...Target) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = targets
+}
+
+type countingRefresher struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *countingRefresher) Refresh(ctx context.Context, domain, file string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[domain]++
+	if domain == "broken.com" {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (r *countingRefresher) count(domain string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[domain]
+}
+
+func TestCrawler_Run(t *testing.T) {
+	logger.Init("info")
+	src := &staticSource{}
+	src.set(
+		Target{Domain: "ok.com", Type: models.FileAdsTxt},
+		Target{Domain: "broken.com", Type: models.FileAdsTxt},
+	)
+	r := &countingRefresher{calls: make(map[string]int)}
+
+	c := New(src, 30*time.Millisecond, 0, 2, logger.L())
+	c.tick = 5 * time.Millisecond
+	c.reloadEvery = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx, r)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if n := r.count("ok.com"); n < 2 {
+		t.Errorf("expected ok.com to be refreshed repeatedly, got %d", n)
+	}
+
+	st := c.Status()
+	if !st.Running || st.Targets != 2 || st.Successes == 0 || st.Failures == 0 || st.LastRun == nil {
+		t.Errorf("unexpected status: %+v", st)
+	}
+	if len(st.Domains) != 2 || st.Domains[0].Domain != "broken.com" || st.Domains[0].LastError == "" {
+		t.Errorf("unexpected target status: %+v", st.Domains)
+	}
+
+	// Targets dropped from the source stop being crawled.
+	src.set(Target{Domain: "ok.com", Type: models.FileAdsTxt})
+	time.Sleep(20 * time.Millisecond)
+	before := r.count("broken.com")
+	time.Sleep(80 * time.Millisecond)
+	if after := r.count("broken.com"); after != before {
+		t.Errorf("expected removed target to stop being crawled, got %d more refreshes", after-before)
+	}
+	if st := c.Status(); st.Targets != 1 {
+		t.Errorf("expected 1 target after reload, got %d", st.Targets)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if c.Status().Running {
+		t.Error("expected crawler to report it stopped")
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	os.WriteFile(path, []byte("# tracked publishers\nMSN.com\n\ncnn.com app-ads # apps\n"), 0o644)
+
+	targets, err := NewFileSource(path).Targets(context.Background())
+	if err != nil {
+		t.Fatalf("Targets: %v", err)
+	}
+	want := []Target{
+		{Domain: "msn.com", Type: models.FileAdsTxt},
+		{Domain: "cnn.com", Type: models.FileAppAdsTxt},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("got %+v, want %+v", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target %d = %+v, want %+v", i, targets[i], want[i])
+		}
+	}
+
+	os.WriteFile(path, []byte("msn.com sellers\n"), 0o644)
+	if _, err := NewFileSource(path).Targets(context.Background()); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+}
+
+func TestMultiSource(t *testing.T) {
+	a := &staticSource{targets: []Target{{Domain: "msn.com", Type: models.FileAdsTxt}}}
+	b := &staticSource{targets: []Target{
+		{Domain: "msn.com", Type: models.FileAdsTxt, Interval: time.Minute},
+		{Domain: "msn.com", Type: models.FileAppAdsTxt},
+	}}
+
+	targets, err := MultiSource{a, b}.Targets(context.Background())
+	if err != nil {
+		t.Fatalf("Targets: %v", err)
+	}
+	if len(targets) != 2 || targets[0].Interval != time.Minute || targets[1].Type != models.FileAppAdsTxt {
+		t.Errorf("unexpected targets: %+v", targets)
+	}
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"ads-txt-service/internal/models"
+)
+
+// Target is a file the crawler keeps warm. A zero Interval means the
+// crawler's default.
+type Target struct {
+	Domain   string
+	Type     string
+	Interval time.Duration
+}
+
+func (t Target) key() string {
+	return t.Type + ":" + t.Domain
+}
+
+// Source supplies the list of targets. It is re-read periodically, so
+// changes are picked up without a restart.
+type Source interface {
+	Targets(ctx context.Context) ([]Target, error)
+}
+
+// FileSource reads targets from a text file with one domain per line,
+// optionally followed by the file type ("ads" or "app-ads"). Blank lines and
+// "#" comments are ignored.
+type FileSource struct {
+	path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+func (f *FileSource) Targets(_ context.Context) ([]Target, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open domains file: %w", err)
+	}
+	defer file.Close()
+
+	var out []Target
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: expected a domain and an optional type", f.path, lineNo)
+		}
+
+		t := Target{Domain: strings.ToLower(fields[0]), Type: models.FileAdsTxt}
+		if len(fields) == 2 {
+			typ, ok := parseType(fields[1])
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: invalid type %q, must be 'ads' or 'app-ads'", f.path, lineNo, fields[1])
+			}
+			t.Type = typ
+		}
+		out = append(out, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read domains file: %w", err)
+	}
+	return out, nil
+}
+
+// parseType maps the short and long names of the supported files to the
+// file name.
+func parseType(s string) (string, bool) {
+	switch strings.ToLower(s) {
+	case "ads", models.FileAdsTxt:
+		return models.FileAdsTxt, true
+	case "app-ads", models.FileAppAdsTxt:
+		return models.FileAppAdsTxt, true
+	}
+	return "", false
+}
+
+// MultiSource merges several sources. A target listed more than once keeps
+// the entry of the last source listing it. An error from any source fails
+// the whole load, so the crawler keeps its previous list.
+type MultiSource []Source
+
+func (m MultiSource) Targets(ctx context.Context) ([]Target, error) {
+	var out []Target
+	index := make(map[string]int)
+	for _, src := range m {
+		targets, err := src.Targets(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range targets {
+			if i, ok := index[t.key()]; ok {
+				out[i] = t
+				continue
+			}
+			index[t.key()] = len(out)
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"ads-txt-service/internal/models"
+)
+
+// Names of the record fields reported in models.RecordChange.
+const (
+	FieldRelationship    = "relationship"
+	FieldCertAuthorityID = "cert_authority_id"
+	FieldExtension       = "extension"
+)
+
+type key struct {
+	adSystem    string
+	publisherID string
+}
+
+// Records compares two record sets. Records are identified by ad system and
+// publisher account ID. Identical records on both sides are unchanged; the
+// remaining records of an identity are paired in file order and reported as
+// changed, and any left over as added or removed. Results are sorted by ad
+// system and account ID.
+func Records(from, to []*models.AdsRecord) *models.RecordDiff {
+	before, after := group(from), group(to)
+	keys := make([]key, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b key) int {
+		return cmp.Or(cmp.Compare(a.adSystem, b.adSystem), cmp.Compare(a.publisherID, b.publisherID))
+	})
+
+	out := &models.RecordDiff{
+		Added:   []*models.AdsRecord{},
+		Removed: []*models.AdsRecord{},
+		Changed: []*models.RecordChange{},
+	}
+	for _, k := range keys {
+		old, cur := unmatched(before[k], after[k])
+		out.Unchanged += len(before[k]) - len(old)
+
+		n := min(len(old), len(cur))
+		for i := 0; i < n; i++ {
+			out.Changed = append(out.Changed, &models.RecordChange{
+				AdSystem:    k.adSystem,
+				PublisherID: k.publisherID,
+				Fields:      changedFields(old[i], cur[i]),
+				From:        old[i],
+				To:          cur[i],
+			})
+		}
+		out.Removed = append(out.Removed, old[n:]...)
+		out.Added = append(out.Added, cur[n:]...)
+	}
+	return out
+}
+
+func group(records []*models.AdsRecord) map[key][]*models.AdsRecord {
+	out := make(map[key][]*models.AdsRecord)
+	for _, rec := range records {
+		k := key{strings.ToLower(rec.AdSystem), rec.PublisherID}
+		out[k] = append(out[k], rec)
+	}
+	return out
+}
+
+// unmatched drops the records present, field for field, on both sides.
+func unmatched(old, cur []*models.AdsRecord) ([]*models.AdsRecord, []*models.AdsRecord) {
+	cur = slices.Clone(cur)
+	var rest []*models.AdsRecord
+	for _, o := range old {
+		i := slices.IndexFunc(cur, func(c *models.AdsRecord) bool { return len(changedFields(o, c)) == 0 })
+		if i < 0 {
+			rest = append(rest, o)
+			continue
+		}
+		cur = slices.Delete(cur, i, i+1)
+	}
+	return rest, cur
+}
+
+func changedFields(a, b *models.AdsRecord) []string {
+	var fields []string
+	if !strings.EqualFold(a.Relationship, b.Relationship) {
+		fields = append(fields, FieldRelationship)
+	}
+	if !strings.EqualFold(a.CertAuthorityID, b.CertAuthorityID) {
+		fields = append(fields, FieldCertAuthorityID)
+	}
+	if a.Extension != b.Extension {
+		fields = append(fields, FieldExtension)
+	}
+	return fields
+}
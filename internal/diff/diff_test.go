@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+
+	"ads-txt-service/internal/models"
+)
+
+func rec(adSystem, id, rel string) *models.AdsRecord {
+	return &models.AdsRecord{AdSystem: adSystem, PublisherID: id, Relationship: rel}
+}
+
+func TestRecords(t *testing.T) {
+	from := []*models.AdsRecord{
+		rec("google.com", "pub-1", models.RelationshipDirect),
+		rec("google.com", "pub-2", models.RelationshipDirect),
+		rec("appnexus.com", "7", models.RelationshipReseller),
+		rec("rubicon.com", "9", models.RelationshipDirect),
+		rec("rubicon.com", "9", models.RelationshipReseller),
+	}
+	to := []*models.AdsRecord{
+		rec("google.com", "pub-1", models.RelationshipDirect),
+		rec("google.com", "pub-2", models.RelationshipReseller),
+		rec("openx.com", "5", models.RelationshipDirect),
+		rec("rubicon.com", "9", models.RelationshipReseller),
+	}
+
+	d := Records(from, to)
+
+	if d.Unchanged != 2 {
+		t.Errorf("Unchanged = %d, want 2", d.Unchanged)
+	}
+	if len(d.Added) != 1 || d.Added[0].AdSystem != "openx.com" {
+		t.Errorf("unexpected added records: %+v", d.Added)
+	}
+	if len(d.Removed) != 2 || d.Removed[0].AdSystem != "appnexus.com" ||
+		d.Removed[1].AdSystem != "rubicon.com" || d.Removed[1].Relationship != models.RelationshipDirect {
+		t.Errorf("unexpected removed records: %+v", d.Removed)
+	}
+	if len(d.Changed) != 1 {
+		t.Fatalf("expected one changed record, got %+v", d.Changed)
+	}
+	c := d.Changed[0]
+	if c.PublisherID != "pub-2" || !slices.Equal(c.Fields, []string{FieldRelationship}) ||
+		c.From.Relationship != models.RelationshipDirect || c.To.Relationship != models.RelationshipReseller {
+		t.Errorf("unexpected change: %+v", c)
+	}
+}
+
+func TestRecords_Identical(t *testing.T) {
+	records := []*models.AdsRecord{rec("google.com", "pub-1", models.RelationshipDirect)}
+	d := Records(records, records)
+	if d.Unchanged != 1 || len(d.Added)+len(d.Removed)+len(d.Changed) != 0 {
+		t.Errorf("expected no changes, got %+v", d)
+	}
+}
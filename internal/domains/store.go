@@ -0,0 +1,174 @@
+package domains
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ads-txt-service/internal/crawler"
+	"ads-txt-service/internal/fsutil"
+	"ads-txt-service/internal/models"
+)
+
+// Store keeps the tracked domains in memory and persists the whole list to a
+// single JSON file on every change. The list is small and rarely written,
+// so rewriting it atomically is simpler than anything incremental. Entries
+// are keyed by type and domain, so a domain's ads.txt and app-ads.txt are
+// tracked separately.
+type Store struct {
+	path string
+
+	mu      sync.RWMutex
+	domains map[string]*models.TrackedDomain
+}
+
+func key(domain, file string) string {
+	return file + ":" + strings.ToLower(domain)
+}
+
+// NewStore loads the list from path, starting empty if it does not exist.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, domains: make(map[string]*models.TrackedDomain)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read domains file: %w", err)
+	}
+	var list []*models.TrackedDomain
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode domains file: %w", err)
+	}
+	for _, d := range list {
+		if d.Type == "" {
+			d.Type = models.FileAdsTxt
+		}
+		s.domains[key(d.Domain, d.Type)] = d
+	}
+	return s, nil
+}
+
+// Put adds or replaces the entry for a domain's file and reports whether it
+// was new. CreatedAt is kept from the existing entry.
+func (s *Store) Put(d *models.TrackedDomain) (bool, error) {
+	d = clone(d)
+	d.Domain = strings.ToLower(d.Domain)
+	k := key(d.Domain, d.Type)
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.domains[k]
+	d.CreatedAt, d.UpdatedAt = now, now
+	if exists {
+		d.CreatedAt = old.CreatedAt
+	}
+	s.domains[k] = d
+	if err := s.saveLocked(); err != nil {
+		if exists {
+			s.domains[k] = old
+		} else {
+			delete(s.domains, k)
+		}
+		return false, err
+	}
+	return !exists, nil
+}
+
+func (s *Store) Get(domain, file string) (*models.TrackedDomain, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.domains[key(domain, file)]
+	if !ok {
+		return nil, false
+	}
+	return clone(d), true
+}
+
+// List returns every tracked domain sorted by domain, then type.
+func (s *Store) List() []*models.TrackedDomain {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listLocked()
+}
+
+// Delete stops tracking a domain's file and reports whether it was
+// tracked.
+func (s *Store) Delete(domain, file string) (bool, error) {
+	k := key(domain, file)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.domains[k]
+	if !ok {
+		return false, nil
+	}
+	delete(s.domains, k)
+	if err := s.saveLocked(); err != nil {
+		s.domains[k] = old
+		return false, err
+	}
+	return true, nil
+}
+
+// Targets makes the store a crawler.Source.
+func (s *Store) Targets(_ context.Context) ([]crawler.Target, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]crawler.Target, 0, len(s.domains))
+	for _, d := range s.domains {
+		out = append(out, crawler.Target{
+			Domain:   d.Domain,
+			Type:     d.Type,
+			Interval: time.Duration(d.CrawlIntervalSeconds) * time.Second,
+		})
+	}
+	return out, nil
+}
+
+func (s *Store) listLocked() []*models.TrackedDomain {
+	out := make([]*models.TrackedDomain, 0, len(s.domains))
+	for _, d := range s.domains {
+		out = append(out, clone(d))
+	}
+	slices.SortFunc(out, func(a, b *models.TrackedDomain) int {
+		if c := strings.Compare(a.Domain, b.Domain); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Type, b.Type)
+	})
+	return out
+}
+
+func (s *Store) saveLocked() error {
+	b, err := json.MarshalIndent(s.listLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode domains: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to save domains: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(s.path, b, 0o644); err != nil {
+		return fmt.Errorf("failed to save domains: %w", err)
+	}
+	return nil
+}
+
+func clone(d *models.TrackedDomain) *models.TrackedDomain {
+	cp := *d
+	cp.Tags = maps.Clone(d.Tags)
+	return &cp
+}
@@ -0,0 +1,75 @@
+package domains
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ads-txt-service/internal/models"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.json")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	created, err := s.Put(&models.TrackedDomain{Domain: "MSN.com", Type: models.FileAdsTxt, Tags: map[string]string{"region": "emea"}})
+	if err != nil || !created {
+		t.Fatalf("Put = %v, %v; want created", created, err)
+	}
+	first, _ := s.Get("msn.com", models.FileAdsTxt)
+
+	created, err = s.Put(&models.TrackedDomain{Domain: "msn.com", Type: models.FileAdsTxt, CrawlIntervalSeconds: 600})
+	if err != nil || created {
+		t.Fatalf("Put = %v, %v; want updated", created, err)
+	}
+	s.Put(&models.TrackedDomain{Domain: "cnn.com", Type: models.FileAppAdsTxt})
+	// The other file of a tracked domain is a separate entry.
+	created, err = s.Put(&models.TrackedDomain{Domain: "msn.com", Type: models.FileAppAdsTxt})
+	if err != nil || !created {
+		t.Fatalf("Put = %v, %v; want created", created, err)
+	}
+
+	// Everything must survive a restart.
+	s, err = NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	list := s.List()
+	if len(list) != 3 || list[0].Domain != "cnn.com" || list[1].Domain != "msn.com" || list[1].Type != models.FileAdsTxt || list[2].Type != models.FileAppAdsTxt {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+	msn := list[1]
+	if msn.Tags != nil || msn.CrawlIntervalSeconds != 600 || !msn.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("update did not replace the entry while keeping created_at: %+v", msn)
+	}
+
+	targets, _ := s.Targets(context.Background())
+	for _, tg := range targets {
+		if tg.Domain == "msn.com" && tg.Type == models.FileAdsTxt && tg.Interval != 10*time.Minute {
+			t.Errorf("expected msn.com to be crawled every 10m, got %v", tg.Interval)
+		}
+	}
+
+	if ok, _ := s.Delete("cnn.com", models.FileAdsTxt); ok {
+		t.Error("expected deleting an untracked file to report false")
+	}
+	if ok, err := s.Delete("cnn.com", models.FileAppAdsTxt); !ok || err != nil {
+		t.Errorf("Delete = %v, %v", ok, err)
+	}
+	if ok, _ := s.Delete("cnn.com", models.FileAppAdsTxt); ok {
+		t.Error("expected deleting an unknown domain to report false")
+	}
+	if _, ok := s.Get("cnn.com", models.FileAppAdsTxt); ok {
+		t.Error("expected cnn.com to be gone")
+	}
+	if ok, _ := s.Delete("MSN.com", models.FileAppAdsTxt); !ok {
+		t.Error("expected msn.com's app-ads.txt to be deleted")
+	}
+	if _, ok := s.Get("msn.com", models.FileAdsTxt); !ok {
+		t.Error("expected msn.com's ads.txt to stay tracked")
+	}
+}
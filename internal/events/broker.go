@@ -0,0 +1,124 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// subscriberBuffer is how many events may queue up for one subscriber. A
+// subscriber that falls further behind is dropped; it can reconnect and
+// resume from the backlog.
+const subscriberBuffer = 64
+
+// Broker fans published events out to every subscriber. It keeps the most
+// recent events so that a client reconnecting with its last seen event ID
+// misses nothing that is still in the backlog.
+type Broker struct {
+	log     *logger.Logger
+	backlog int
+
+	mu     sync.Mutex
+	nextID uint64
+	recent []*models.StreamEvent
+	subs   map[chan *models.StreamEvent]struct{}
+	closed bool
+}
+
+// NewBroker returns a broker remembering the last backlog events.
+func NewBroker(backlog int, log *logger.Logger) *Broker {
+	return &Broker{
+		log:     log,
+		backlog: backlog,
+		subs:    make(map[chan *models.StreamEvent]struct{}),
+	}
+}
+
+// Publish sends payload, encoded as JSON, to every subscriber as an event
+// of the given name about domain's file.
+func (b *Broker) Publish(event, domain, file string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		b.log.Errorw("Failed to encode event", zap.Error(err), "event", event, "domain", domain)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.nextID++
+	ev := &models.StreamEvent{ID: b.nextID, Event: event, Domain: domain, Type: file, Data: data}
+	if b.backlog > 0 {
+		if len(b.recent) == b.backlog {
+			copy(b.recent, b.recent[1:])
+			b.recent = b.recent[:len(b.recent)-1]
+		}
+		b.recent = append(b.recent, ev)
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			b.log.Warnw("Dropping slow event subscriber", "event_id", ev.ID)
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Notify publishes a change event, so the broker can be registered as a
+// change notifier.
+func (b *Broker) Notify(ev *models.ChangeEvent) {
+	b.Publish(ev.Event, ev.Domain, ev.Type, ev)
+}
+
+// Subscribe returns the backlogged events published after lastID, followed
+// on ch by every event published from now on. lastID 0 skips the backlog.
+// ch is closed when the subscriber falls behind or the broker is closed;
+// cancel must be called once the caller stops reading.
+func (b *Broker) Subscribe(lastID uint64) (replay []*models.StreamEvent, ch <-chan *models.StreamEvent, cancel func()) {
+	c := make(chan *models.StreamEvent, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lastID > 0 {
+		for _, ev := range b.recent {
+			if ev.ID > lastID {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	if b.closed {
+		close(c)
+		return replay, c, func() {}
+	}
+	b.subs[c] = struct{}{}
+
+	return replay, c, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[c]; ok {
+			delete(b.subs, c)
+			close(c)
+		}
+	}
+}
+
+// Close ends every subscription, letting open streams finish so the HTTP
+// server can shut down. Later events are discarded.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
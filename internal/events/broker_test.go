@@ -0,0 +1,72 @@
+package events
+
+import (
+	"testing"
+
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+)
+
+func TestBroker(t *testing.T) {
+	logger.Init("info")
+	b := NewBroker(2, logger.L())
+
+	b.Publish(models.EventFetchCompleted, "msn.com", models.FileAdsTxt, map[string]int{"n": 1})
+	replay, ch, cancel := b.Subscribe(0)
+	if len(replay) != 0 {
+		t.Errorf("expected no replay without a last event ID, got %d", len(replay))
+	}
+
+	b.Notify(&models.ChangeEvent{Event: models.EventAdsChanged, Domain: "cnn.com", Type: models.FileAdsTxt})
+	ev := <-ch
+	if ev.ID != 2 || ev.Event != models.EventAdsChanged || ev.Domain != "cnn.com" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed after cancel")
+	}
+
+	// Only the last two events are kept.
+	b.Publish(models.EventCacheRefreshed, "msn.com", models.FileAdsTxt, nil)
+	replay, _, cancel = b.Subscribe(1)
+	defer cancel()
+	if len(replay) != 2 || replay[0].ID != 2 || replay[1].ID != 3 {
+		t.Errorf("unexpected replay: %+v", replay)
+	}
+}
+
+func TestBroker_DropsSlowSubscribers(t *testing.T) {
+	logger.Init("info")
+	b := NewBroker(0, logger.L())
+	_, ch, cancel := b.Subscribe(0)
+	defer cancel()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish(models.EventFetchCompleted, "msn.com", models.FileAdsTxt, nil)
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected %d buffered events before the channel closed, got %d", subscriberBuffer, n)
+	}
+}
+
+func TestBroker_Close(t *testing.T) {
+	logger.Init("info")
+	b := NewBroker(10, logger.L())
+	_, ch, cancel := b.Subscribe(0)
+	defer cancel()
+
+	b.Close()
+	if _, ok := <-ch; ok {
+		t.Error("expected Close to end the subscription")
+	}
+	b.Publish(models.EventFetchCompleted, "msn.com", models.FileAdsTxt, nil)
+	_, ch, _ = b.Subscribe(0)
+	if _, ok := <-ch; ok {
+		t.Error("expected subscriptions after Close to be closed")
+	}
+}
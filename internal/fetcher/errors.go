@@ -0,0 +1,99 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Failure kinds carried by Error. They are stable and safe to expose to API
+// clients.
+const (
+	KindNotFound       = "not_found"
+	KindForbidden      = "forbidden"
+	KindTimeout        = "timeout"
+	KindDNS            = "dns_failure"
+	KindTLS            = "tls_failure"
+	KindTooLarge       = "too_large"
+	KindBadContentType = "bad_content_type"
+	KindRedirect       = "redirect_violation"
+	KindHTTPStatus     = "http_status"
+	KindConnection     = "connection_failure"
+)
+
+var errRedirect = errors.New("redirect policy violation")
+
+// Error is returned by the Fetcher for every failed fetch.
+type Error struct {
+	Kind       string
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("fetch %s failed (%s): status %d", e.URL, e.Kind, e.StatusCode)
+	}
+	return fmt.Sprintf("fetch %s failed (%s): %v", e.URL, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// fromResponse reports whether the server answered at all, which makes the
+// error more telling than a connection-level failure.
+func (e *Error) fromResponse() bool {
+	switch e.Kind {
+	case KindNotFound, KindForbidden, KindHTTPStatus, KindTooLarge, KindBadContentType, KindRedirect:
+		return true
+	}
+	return false
+}
+
+// Classify returns the failure kind of err. Errors that did not come from
+// the Fetcher are classified by inspecting the underlying network error.
+func Classify(err error) string {
+	var fe *Error
+	if errors.As(err, &fe) {
+		return fe.Kind
+	}
+	return classifyTransport(err)
+}
+
+func classifyTransport(err error) string {
+	if errors.Is(err, errRedirect) {
+		return KindRedirect
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return KindTimeout
+		}
+		return KindDNS
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return KindTimeout
+	}
+
+	var (
+		certErr     *tls.CertificateVerificationError
+		unknownCA   x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		invalidErr  x509.CertificateInvalidError
+		recordErr   tls.RecordHeaderError
+		alertErr    tls.AlertError
+	)
+	if errors.As(err, &certErr) || errors.As(err, &unknownCA) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) {
+		return KindTLS
+	}
+	return KindConnection
+}
@@ -4,36 +4,196 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 )
 
+// maxRedirects is the number of redirects the ads.txt spec allows a crawler
+// to follow, and only while they stay within the root domain.
+const maxRedirects = 1
+
+// maxSellersJSONSize caps sellers.json downloads. The largest exchanges
+// publish files far bigger than any ads.txt.
+const maxSellersJSONSize = 256 << 20
+
+var (
+	adsTxtContentTypes  = []string{"text/plain", "application/octet-stream"}
+	sellersContentTypes = []string{"application/json", "text/plain", "application/octet-stream"}
+)
+
 type Fetcher struct {
-	Timeout time.Duration
+	// Timeout bounds one file fetch, including every fallback location
+	// tried, and a single sellers.json download.
+	Timeout     time.Duration
+	MaxBodySize int64
+	// Transport makes the requests; nil means http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+func NewFetcher(timeout time.Duration, maxBodySize int64) *Fetcher {
+	return &Fetcher{Timeout: timeout, MaxBodySize: maxBodySize}
+}
+
+// Result is a fetched file together with the response that served it.
+type Result struct {
+	Body          string
+	URL           string
+	RedirectChain []string
+	StatusCode    int
+	Header        http.Header
+	FetchedAt     time.Time
+}
+
+func (f *Fetcher) FetchAdsTxt(ctx context.Context, domain string) (*Result, error) {
+	return f.fetch(ctx, domain, "ads.txt")
 }
 
-func NewFetcher(timeout time.Duration) *Fetcher {
-	return &Fetcher{Timeout: timeout}
+// FetchAppAdsTxt fetches the app-ads.txt file that mobile and CTV app
+// developers publish on the developer domain listed in the app store.
+func (f *Fetcher) FetchAppAdsTxt(ctx context.Context, domain string) (*Result, error) {
+	return f.fetch(ctx, domain, "app-ads.txt")
 }
 
-func (f *Fetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
-	url := fmt.Sprintf("https://%s/ads.txt", domain)
+// FetchSellersJSON fetches the sellers.json an ad system publishes at the
+// root of its domain. Unlike ads.txt there is no HTTP or www fallback.
+func (f *Fetcher) FetchSellersJSON(ctx context.Context, domain string) (*Result, error) {
+	return f.get(ctx, fmt.Sprintf("https://%s/sellers.json", domain), rootDomain(domain), maxSellersJSONSize, sellersContentTypes)
+}
+
+// fetch tries the locations the spec tells crawlers to check, in order:
+// HTTPS then HTTP on the domain itself, then the same on its www subdomain.
+// The first successful response wins. When every location fails, an error
+// from a server that actually answered is preferred over a connection error
+// since it says more about the publisher's setup. All attempts share one
+// Timeout, so an unresponsive host cannot multiply it.
+func (f *Fetcher) fetch(ctx context.Context, domain, file string) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+
+	hosts := []string{domain}
+	if !strings.HasPrefix(domain, "www.") {
+		hosts = append(hosts, "www."+domain)
+	}
+
+	var firstErr, responseErr *Error
+	for _, host := range hosts {
+		for _, scheme := range []string{"https", "http"} {
+			url := fmt.Sprintf("%s://%s/%s", scheme, host, file)
+			res, err := f.get(ctx, url, rootDomain(domain), f.MaxBodySize, adsTxtContentTypes)
+			if err == nil {
+				return res, nil
+			}
+			fe := err.(*Error)
+			if ctx.Err() != nil {
+				return nil, fe
+			}
+			if firstErr == nil {
+				firstErr = fe
+			}
+			if responseErr == nil && fe.fromResponse() {
+				responseErr = fe
+			}
+		}
+	}
+	if responseErr != nil {
+		return nil, responseErr
+	}
+	return nil, firstErr
+}
+
+// get fetches a single URL. Every error it returns is an *Error.
+func (f *Fetcher) get(ctx context.Context, url, root string, maxSize int64, contentTypes []string) (*Result, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", err
+		return nil, &Error{Kind: KindConnection, URL: url, Err: err}
+	}
+
+	var chain []string
+	cl := &http.Client{
+		Transport: f.Transport,
+		Timeout:   f.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("%w: stopped after %d redirect(s)", errRedirect, maxRedirects)
+			}
+			if !withinRoot(req.URL.Hostname(), root) {
+				return fmt.Errorf("%w: redirect to %s leaves root domain %s", errRedirect, req.URL.Hostname(), root)
+			}
+			chain = append(chain, via[len(via)-1].URL.String())
+			return nil
+		},
 	}
-	cl := &http.Client{Timeout: f.Timeout}
 	resp, err := cl.Do(req)
 	if err != nil {
-		return "", err
+		return nil, &Error{Kind: classifyTransport(err), URL: url, Err: err}
 	}
 	defer resp.Body.Close()
+
+	finalURL := resp.Request.URL.String()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("fetch failed: %s", resp.Status)
+		return nil, &Error{
+			Kind:       statusKind(resp.StatusCode),
+			URL:        finalURL,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("unexpected status %s", resp.Status),
+		}
 	}
-	b, err := io.ReadAll(resp.Body)
+
+	if ct := resp.Header.Get("Content-Type"); !allowedContentType(ct, contentTypes) {
+		return nil, &Error{Kind: KindBadContentType, URL: finalURL, Err: fmt.Errorf("unexpected content type %q", ct)}
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
-		return "", err
+		return nil, &Error{Kind: classifyTransport(err), URL: finalURL, Err: err}
+	}
+	if int64(len(b)) > maxSize {
+		return nil, &Error{Kind: KindTooLarge, URL: finalURL, Err: fmt.Errorf("body exceeds %d bytes", maxSize)}
+	}
+
+	return &Result{
+		Body:          string(b),
+		URL:           finalURL,
+		RedirectChain: chain,
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header.Clone(),
+		FetchedAt:     time.Now().UTC(),
+	}, nil
+}
+
+func statusKind(code int) string {
+	switch code {
+	case http.StatusNotFound, http.StatusGone:
+		return KindNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return KindForbidden
 	}
-	return string(b), nil
+	return KindHTTPStatus
+}
+
+// allowedContentType accepts a missing Content-Type, since plenty of
+// servers omit it for .txt files.
+func allowedContentType(header string, allowed []string) bool {
+	if header == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(allowed, mt)
+}
+
+// rootDomain strips a leading www. label so redirects between the bare
+// domain and its www subdomain stay in scope.
+func rootDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(domain), "www.")
+}
+
+func withinRoot(host, root string) bool {
+	host = strings.ToLower(host)
+	return host == root || strings.HasSuffix(host, "."+root)
 }
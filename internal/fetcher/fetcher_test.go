@@ -0,0 +1,219 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetcher_RedirectPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ads.txt":
+			w.Write([]byte("google.com, pub-1, DIRECT\n"))
+		case "/one":
+			http.Redirect(w, r, "/ads.txt", http.StatusFound)
+		case "/two":
+			http.Redirect(w, r, "/one", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, "http://elsewhere.example/ads.txt", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(time.Second, 1<<20)
+	root := "127.0.0.1"
+
+	t.Run("SingleRedirectWithinRoot", func(t *testing.T) {
+		res, err := f.get(context.Background(), srv.URL+"/one", root, f.MaxBodySize, adsTxtContentTypes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res.URL != srv.URL+"/ads.txt" {
+			t.Errorf("unexpected final URL %q", res.URL)
+		}
+		if len(res.RedirectChain) != 1 || res.RedirectChain[0] != srv.URL+"/one" {
+			t.Errorf("unexpected redirect chain %v", res.RedirectChain)
+		}
+		if res.Header.Get("Content-Type") == "" || res.FetchedAt.IsZero() {
+			t.Errorf("expected response headers and fetch time, got %v at %v", res.Header, res.FetchedAt)
+		}
+	})
+
+	t.Run("TooManyRedirects", func(t *testing.T) {
+		if _, err := f.get(context.Background(), srv.URL+"/two", root, f.MaxBodySize, adsTxtContentTypes); Classify(err) != KindRedirect {
+			t.Errorf("expected redirect error, got %v", err)
+		}
+	})
+
+	t.Run("RedirectOutsideRoot", func(t *testing.T) {
+		_, err := f.get(context.Background(), srv.URL+"/away", root, f.MaxBodySize, adsTxtContentTypes)
+		if Classify(err) != KindRedirect || !strings.Contains(err.Error(), "leaves root domain") {
+			t.Errorf("expected root domain error, got %v", err)
+		}
+	})
+}
+
+func TestWithinRoot(t *testing.T) {
+	cases := []struct {
+		host, domain string
+		want         bool
+	}{
+		{"example.com", "example.com", true},
+		{"www.example.com", "example.com", true},
+		{"example.com", "www.example.com", true},
+		{"cdn.example.com", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"example.org", "example.com", false},
+	}
+	for _, c := range cases {
+		if got := withinRoot(c.host, rootDomain(c.domain)); got != c.want {
+			t.Errorf("withinRoot(%q, %q) = %v, want %v", c.host, c.domain, got, c.want)
+		}
+	}
+}
+
+func TestFetcher_TypedErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html></html>"))
+		case "/large":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(strings.Repeat("a", 64)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(time.Second, 32)
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/missing", KindNotFound},
+		{"/forbidden", KindForbidden},
+		{"/error", KindHTTPStatus},
+		{"/html", KindBadContentType},
+		{"/large", KindTooLarge},
+	}
+	for _, c := range cases {
+		_, err := f.get(context.Background(), srv.URL+c.path, "127.0.0.1", f.MaxBodySize, adsTxtContentTypes)
+		var fe *Error
+		if !errors.As(err, &fe) || fe.Kind != c.want {
+			t.Errorf("%s: expected %s error, got %v", c.path, c.want, err)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&Error{Kind: KindNotFound}, KindNotFound},
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, KindDNS},
+		{context.DeadlineExceeded, KindTimeout},
+		{x509.UnknownAuthorityError{}, KindTLS},
+		{errRedirect, KindRedirect},
+		{errors.New("connection refused"), KindConnection},
+	}
+	for _, c := range cases {
+		if got := Classify(c.err); got != c.want {
+			t.Errorf("Classify(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+// fakeTransport answers each URL with a canned status, or refuses the
+// connection, and
+// records the order in which URLs were requested.
+type fakeTransport struct {
+	responses map[string]int
+	block     bool
+	requested []string
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	url := req.URL.String()
+	t.requested = append(t.requested, url)
+	if t.block {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	status, ok := t.responses[url]
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       io.NopCloser(strings.NewReader("google.com, pub-1, DIRECT\n")),
+		Request:    req,
+	}, nil
+}
+
+func TestFetcher_FallbackOrder(t *testing.T) {
+	tr := &fakeTransport{responses: map[string]int{
+		"http://example.com/ads.txt":      http.StatusNotFound,
+		"https://www.example.com/ads.txt": http.StatusOK,
+	}}
+	f := &Fetcher{Timeout: time.Second, MaxBodySize: 1 << 20, Transport: tr}
+
+	res, err := f.FetchAdsTxt(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.URL != "https://www.example.com/ads.txt" {
+		t.Errorf("unexpected final URL %q", res.URL)
+	}
+	want := []string{"https://example.com/ads.txt", "http://example.com/ads.txt", "https://www.example.com/ads.txt"}
+	if !slices.Equal(tr.requested, want) {
+		t.Errorf("requested %v, want %v", tr.requested, want)
+	}
+
+	// When every location fails, the answer of a server that responded
+	// wins over connection errors, and www. is not doubled.
+	tr = &fakeTransport{responses: map[string]int{"http://www.example.com/app-ads.txt": http.StatusForbidden}}
+	f.Transport = tr
+	_, err = f.FetchAppAdsTxt(context.Background(), "www.example.com")
+	if Classify(err) != KindForbidden {
+		t.Errorf("expected the forbidden response to be reported, got %v", err)
+	}
+	want = []string{"https://www.example.com/app-ads.txt", "http://www.example.com/app-ads.txt"}
+	if !slices.Equal(tr.requested, want) {
+		t.Errorf("requested %v, want %v", tr.requested, want)
+	}
+}
+
+func TestFetcher_SharedTimeout(t *testing.T) {
+	tr := &fakeTransport{block: true}
+	f := &Fetcher{Timeout: 50 * time.Millisecond, MaxBodySize: 1 << 20, Transport: tr}
+
+	start := time.Now()
+	_, err := f.FetchAdsTxt(context.Background(), "example.com")
+	if Classify(err) != KindTimeout {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("fetch took %v, want about one timeout", elapsed)
+	}
+	if len(tr.requested) != 1 {
+		t.Errorf("expected no fallback once the deadline passed, got %v", tr.requested)
+	}
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ads-txt-service/internal/apierror"
+	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// maxBatchBodySize bounds the JSON body of a batch request.
+const maxBatchBodySize = 1 << 20
+
+// BatchAds looks up many domains in one request. Domains are served from the
+// cache where possible and otherwise fetched by a bounded pool of workers,
+// sharing in-flight fetches with /ads. A failure for one domain is reported
+// in its result and does not fail the batch.
+//
+// With stream=true each result is written as a line of NDJSON as soon as it
+// is ready, in completion order, instead of one buffered response.
+func (s *Server) BatchAds(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	stream, ok := streamParam(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+	var req models.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "batch body too large", "")
+			return
+		}
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid JSON body", "")
+		return
+	}
+
+	file, ok := fileType(req.Type)
+	if !ok {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid type, must be 'ads' or 'app-ads'", "")
+		return
+	}
+	domains := uniqueDomains(req.Domains)
+	switch {
+	case len(domains) == 0:
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidBody, "domains must not be empty", "")
+		return
+	case len(domains) > s.cfg.BatchMaxDomains:
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidBody,
+			fmt.Sprintf("too many domains: got %d, at most %d allowed", len(domains), s.cfg.BatchMaxDomains), "")
+		return
+	}
+
+	// A large batch can take far longer than the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.log.Warnw("Failed to clear write deadline", zap.Error(err))
+	}
+
+	s.log.Infow("Batch lookup", "domains", len(domains), "file", file, "stream", stream)
+	if stream {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		s.runBatch(ctx, domains, file, func(_ int, res *models.BatchResult) {
+			if err := enc.Encode(res); err != nil {
+				return
+			}
+			rc.Flush()
+		})
+		return
+	}
+
+	results := make([]*models.BatchResult, len(domains))
+	s.runBatch(ctx, domains, file, func(i int, res *models.BatchResult) {
+		results[i] = res
+	})
+	if ctx.Err() != nil {
+		s.log.Infow("Client went away during batch lookup", "domains", len(domains), "file", file)
+		return
+	}
+
+	resp := &models.BatchResponse{Total: len(results), Results: results}
+	for _, res := range results {
+		if res.Error != nil {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// runBatch looks up every domain with at most BatchConcurrency workers. emit
+// is called once per processed domain with its index, never concurrently.
+// Domains not yet started when ctx is cancelled are skipped.
+func (s *Server) runBatch(ctx context.Context, domains []string, file string, emit func(int, *models.BatchResult)) {
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < min(s.cfg.BatchConcurrency, len(domains)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res := s.batchLookup(ctx, domains[i], file)
+				mu.Lock()
+				emit(i, res)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range domains {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (s *Server) batchLookup(ctx context.Context, domain, file string) *models.BatchResult {
+	out := &models.BatchResult{Domain: domain}
+	if !isValidDomain(domain) {
+		out.Error = &models.ErrorResponse{Code: apierror.CodeInvalidDomain, Message: "invalid domain", Domain: domain}
+		return out
+	}
+
+	resp, err := s.lookup(ctx, domain, file)
+	if err != nil {
+		_, out.Error = fetchErrorResponse(fetcher.Classify(err), file, domain)
+		return out
+	}
+	out.Result = resp
+	return out
+}
+
+// uniqueDomains normalises the requested domains and drops blanks and
+// duplicates, keeping the first occurrence's position.
+func uniqueDomains(domains []string) []string {
+	seen := make(map[string]bool, len(domains))
+	out := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		out = append(out, d)
+	}
+	return out
+}
+
+func streamParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	raw := r.URL.Query().Get("stream")
+	if raw == "" {
+		return false, true
+	}
+	stream, err := strconv.ParseBool(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid stream, must be a boolean", "")
+		return false, false
+	}
+	return stream, true
+}
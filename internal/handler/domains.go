@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ads-txt-service/internal/apierror"
+	"ads-txt-service/internal/models"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxDomainBodySize bounds the JSON body of a tracked domain.
+	maxDomainBodySize = 64 << 10
+	// minCrawlInterval keeps a per-domain interval from hammering a
+	// publisher.
+	minCrawlInterval = 60
+)
+
+// PutDomain registers a domain's file for tracking, or replaces its
+// settings when it is already tracked. A domain's ads.txt and app-ads.txt
+// are tracked as separate entries. The crawler picks up changes on its next reload.
+func (s *Server) PutDomain(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDomainBodySize)
+	var d models.TrackedDomain
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "domain body too large", "")
+			return
+		}
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid JSON body", "")
+		return
+	}
+
+	d.Domain = strings.ToLower(strings.TrimSpace(d.Domain))
+	switch {
+	case d.Domain == "":
+		writeError(w, http.StatusBadRequest, apierror.CodeMissingDomain, "missing domain", "")
+		return
+	case !isValidDomain(d.Domain):
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidDomain, "invalid domain", d.Domain)
+		return
+	}
+	file, ok := fileType(d.Type)
+	if !ok {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid type, must be 'ads' or 'app-ads'", d.Domain)
+		return
+	}
+	d.Type = file
+	if d.CrawlIntervalSeconds != 0 && d.CrawlIntervalSeconds < minCrawlInterval {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter,
+			fmt.Sprintf("invalid crawl_interval_seconds, must be 0 or at least %d", minCrawlInterval), d.Domain)
+		return
+	}
+	for k := range d.Tags {
+		if strings.TrimSpace(k) == "" {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "tag names must not be empty", d.Domain)
+			return
+		}
+	}
+
+	created, err := s.domains.Put(&d)
+	if err != nil {
+		s.log.Errorw("Failed to save tracked domain", zap.Error(err), "domain", d.Domain)
+		writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to save domain", d.Domain)
+		return
+	}
+	saved, _ := s.domains.Get(d.Domain, d.Type)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSONStatus(w, status, saved)
+}
+
+// ListDomains lists the tracked domains. Each tag=name:value parameter
+// narrows the list to domains carrying that tag.
+func (s *Server) ListDomains(w http.ResponseWriter, r *http.Request) {
+	filters, ok := tagParams(w, r)
+	if !ok {
+		return
+	}
+	out := []*models.TrackedDomain{}
+	for _, d := range s.domains.List() {
+		if hasTags(d, filters) {
+			out = append(out, d)
+		}
+	}
+	writeJSON(w, &models.DomainsResponse{Total: len(out), Domains: out})
+}
+
+// GetDomain returns the tracking entry of the domain's file selected by
+// type, ads.txt by default.
+func (s *Server) GetDomain(w http.ResponseWriter, r *http.Request) {
+	domain := mux.Vars(r)["domain"]
+	file, ok := fileParam(w, r)
+	if !ok {
+		return
+	}
+	d, ok := s.domains.Get(domain, file)
+	if !ok {
+		writeError(w, http.StatusNotFound, apierror.CodeDomainNotFound, file+" of "+domain+" is not tracked", domain)
+		return
+	}
+	writeJSON(w, d)
+}
+
+// DeleteDomain stops tracking the domain's file selected by type, ads.txt
+// by default.
+func (s *Server) DeleteDomain(w http.ResponseWriter, r *http.Request) {
+	domain := mux.Vars(r)["domain"]
+	file, ok := fileParam(w, r)
+	if !ok {
+		return
+	}
+	ok, err := s.domains.Delete(domain, file)
+	if err != nil {
+		s.log.Errorw("Failed to delete tracked domain", zap.Error(err), "domain", domain)
+		writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to delete domain", domain)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, apierror.CodeDomainNotFound, file+" of "+domain+" is not tracked", domain)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tagParams parses the repeatable tag=name:value filter.
+func tagParams(w http.ResponseWriter, r *http.Request) (map[string]string, bool) {
+	filters := make(map[string]string)
+	for _, raw := range r.URL.Query()["tag"] {
+		name, value, ok := strings.Cut(raw, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid tag, must be name:value", "")
+			return nil, false
+		}
+		filters[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return filters, true
+}
+
+func hasTags(d *models.TrackedDomain, filters map[string]string) bool {
+	for name, value := range filters {
+		if v, ok := d.Tags[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
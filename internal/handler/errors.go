@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"ads-txt-service/internal/apierror"
+	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/models"
+)
+
+// fetchErrorStatus maps fetcher failure kinds to the status returned to API
+// clients. The failure kind itself is used as the response's error code.
+var fetchErrorStatus = map[string]int{
+	fetcher.KindNotFound:       http.StatusNotFound,
+	fetcher.KindForbidden:      http.StatusForbidden,
+	fetcher.KindTimeout:        http.StatusGatewayTimeout,
+	fetcher.KindDNS:            http.StatusBadGateway,
+	fetcher.KindTLS:            http.StatusBadGateway,
+	fetcher.KindConnection:     http.StatusBadGateway,
+	fetcher.KindHTTPStatus:     http.StatusBadGateway,
+	fetcher.KindTooLarge:       http.StatusUnprocessableEntity,
+	fetcher.KindBadContentType: http.StatusUnprocessableEntity,
+	fetcher.KindRedirect:       http.StatusUnprocessableEntity,
+}
+
+// fetchErrorMessage describes a failure kind without leaking the underlying
+// Go error to clients.
+func fetchErrorMessage(kind, file, domain string) string {
+	switch kind {
+	case fetcher.KindNotFound:
+		return fmt.Sprintf("%s has no %s", domain, file)
+	case fetcher.KindForbidden:
+		return fmt.Sprintf("%s refused access to its %s", domain, file)
+	case fetcher.KindTimeout:
+		return fmt.Sprintf("timed out fetching %s from %s", file, domain)
+	case fetcher.KindDNS:
+		return fmt.Sprintf("%s could not be resolved", domain)
+	case fetcher.KindTLS:
+		return fmt.Sprintf("TLS handshake with %s failed", domain)
+	case fetcher.KindTooLarge:
+		return fmt.Sprintf("%s of %s exceeds the maximum size", file, domain)
+	case fetcher.KindBadContentType:
+		return fmt.Sprintf("%s of %s is not served as text/plain", file, domain)
+	case fetcher.KindRedirect:
+		return fmt.Sprintf("%s of %s redirects outside the allowed policy", file, domain)
+	case fetcher.KindHTTPStatus:
+		return fmt.Sprintf("%s returned an error status for %s", domain, file)
+	default:
+		return fmt.Sprintf("%s is unreachable", domain)
+	}
+}
+
+func fetchErrorResponse(kind, file, domain string) (int, *models.ErrorResponse) {
+	status, ok := fetchErrorStatus[kind]
+	if !ok {
+		status = http.StatusBadGateway
+	}
+	return status, &models.ErrorResponse{Code: kind, Message: fetchErrorMessage(kind, file, domain), Domain: domain}
+}
+
+func writeFetchError(w http.ResponseWriter, kind, file, domain string) {
+	status, resp := fetchErrorResponse(kind, file, domain)
+	apierror.Write(w, status, resp)
+}
+
+// writeError sends one of the API's own errors. domain may be empty.
+func writeError(w http.ResponseWriter, status int, code, message, domain string) {
+	e := apierror.New(code, message)
+	e.Domain = domain
+	apierror.Write(w, status, e)
+}
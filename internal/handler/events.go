@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ads-txt-service/internal/apierror"
+	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// eventFilter narrows the stream to some domains, to tracked domains with
+// the given tags, or both. The zero value lets every event through.
+type eventFilter struct {
+	domains map[string]bool
+	tags    map[string]string
+}
+
+// StreamEvents streams fetch completions, cache refreshes and change events
+// as Server-Sent Events. Each domain parameter, which may hold a comma
+// separated list, limits the stream to those domains; each tag=name:value
+// limits it to tracked domains carrying that tag. A client reconnecting
+// with Last-Event-ID first receives the events it missed, as far as the
+// backlog reaches.
+func (s *Server) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	filter, ok := s.eventFilterParams(w, r)
+	if !ok {
+		return
+	}
+	lastID, ok := lastEventID(w, r)
+	if !ok {
+		return
+	}
+
+	// The stream stays open far longer than the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.log.Warnw("Failed to clear write deadline", zap.Error(err))
+	}
+
+	replay, ch, cancel := s.events.Subscribe(lastID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, ev := range replay {
+		if s.matchEvent(filter, ev) {
+			writeEvent(w, ev)
+		}
+	}
+	rc.Flush()
+
+	s.log.Infow("Event stream opened", "domains", len(filter.domains), "tags", len(filter.tags), "last_event_id", lastID)
+	heartbeat := time.NewTicker(s.cfg.EventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			s.log.Infow("Event stream closed by client")
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				// Shutting down, or the client fell too far behind; it
+				// reconnects and catches up from the backlog.
+				return
+			}
+			if !s.matchEvent(filter, ev) {
+				continue
+			}
+			err = writeEvent(w, ev)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev *models.StreamEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Event, ev.Data)
+	return err
+}
+
+func (s *Server) eventFilterParams(w http.ResponseWriter, r *http.Request) (eventFilter, bool) {
+	var f eventFilter
+	for _, raw := range r.URL.Query()["domain"] {
+		for _, d := range strings.Split(raw, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if d == "" {
+				continue
+			}
+			if !isValidDomain(d) {
+				writeError(w, http.StatusBadRequest, apierror.CodeInvalidDomain, "invalid domain", d)
+				return f, false
+			}
+			if f.domains == nil {
+				f.domains = make(map[string]bool)
+			}
+			f.domains[d] = true
+		}
+	}
+
+	tags, ok := tagParams(w, r)
+	if !ok {
+		return f, false
+	}
+	if len(tags) > 0 {
+		if s.domains == nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "tag filters need tracked domains, which are not enabled", "")
+			return f, false
+		}
+		f.tags = tags
+	}
+	return f, true
+}
+
+func (s *Server) matchEvent(f eventFilter, ev *models.StreamEvent) bool {
+	domain := strings.ToLower(ev.Domain)
+	if len(f.domains) > 0 && !f.domains[domain] {
+		return false
+	}
+	if len(f.tags) > 0 {
+		d, ok := s.domains.Get(domain, ev.Type)
+		if !ok || !hasTags(d, f.tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func lastEventID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	raw := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid Last-Event-ID, must be an event id", "")
+		return 0, false
+	}
+	return id, true
+}
+
+// publishFetch reports a finished fetch on the event stream, if enabled.
+func (s *Server) publishFetch(domain, file string, start time.Time, res *fetcher.Result, err error) {
+	if s.events == nil {
+		return
+	}
+	ev := &models.FetchEvent{
+		Event:      models.EventFetchCompleted,
+		Domain:     domain,
+		Type:       file,
+		DurationMS: time.Since(start).Milliseconds(),
+		Timestamp:  time.Now().UTC(),
+	}
+	if res != nil {
+		ev.URL = res.URL
+		ev.StatusCode = res.StatusCode
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		_, ev.Error = fetchErrorResponse(fetcher.Classify(err), file, domain)
+	}
+	s.events.Publish(ev.Event, domain, file, ev)
+}
+
+// publishCacheRefresh reports a newly cached copy on the event stream, if
+// enabled.
+func (s *Server) publishCacheRefresh(resp *models.AdsResponse) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(models.EventCacheRefreshed, resp.Domain, resp.Type, &models.CacheEvent{
+		Event:        models.EventCacheRefreshed,
+		Domain:       resp.Domain,
+		Type:         resp.Type,
+		FetchedAt:    resp.Timestamp,
+		TotalRecords: resp.TotalRecords,
+		TTLSeconds:   int(s.cfg.CacheTTL.Seconds()),
+	})
+}
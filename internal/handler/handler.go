@@ -1,47 +1,191 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"ads-txt-service/internal/apierror"
 	"ads-txt-service/internal/cache"
+	"ads-txt-service/internal/coalesce"
 	"ads-txt-service/internal/config"
 	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/index"
 	"ads-txt-service/internal/logger"
-	"ads-txt-service/internal/middleware" 
+	"ads-txt-service/internal/middleware"
 	"ads-txt-service/internal/models"
 	"ads-txt-service/internal/parser"
+	"ads-txt-service/internal/storage"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
+const (
+	maxUploadSize   = 5 << 20
+	uploadFormField = "file"
+	// maxSubdomains caps how many SUBDOMAIN files are crawled for one domain.
+	maxSubdomains = 20
+)
 
 type AdsCache interface {
 	GetAds(ctx context.Context, key string) (*models.AdsResponse, bool)
 	SetAds(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error
+	GetFailure(ctx context.Context, key string) (*models.FetchFailure, bool)
+	SetFailure(ctx context.Context, key string, f *models.FetchFailure, ttl time.Duration) error
 }
 
 type AdsFetcher interface {
-	FetchAdsTxt(ctx context.Context, domain string) (string, error)
+	FetchAdsTxt(ctx context.Context, domain string) (*fetcher.Result, error)
+	FetchAppAdsTxt(ctx context.Context, domain string) (*fetcher.Result, error)
 }
 
 type AdsParser interface {
-	ParseAdsTxt(r io.Reader) map[string]int
+	ParseAdsTxt(r io.Reader) *parser.Result
+}
+
+type SellersVerifier interface {
+	Verify(ctx context.Context, records []*models.AdsRecord)
+}
+
+type CrawlerStatus interface {
+	Status() *models.CrawlerStatus
+}
+
+type DomainStore interface {
+	Put(d *models.TrackedDomain) (bool, error)
+	Get(domain, file string) (*models.TrackedDomain, bool)
+	List() []*models.TrackedDomain
+	Delete(domain, file string) (bool, error)
+}
+
+type ChangeNotifier interface {
+	Notify(ev *models.ChangeEvent)
+}
+
+type WebhookRegistry interface {
+	Add(h *models.Webhook) error
+	List() []*models.Webhook
+	Delete(id string) (bool, error)
+}
+
+// EventStream receives the events streamed on GET /events. Its change
+// events come from Notify.
+type EventStream interface {
+	ChangeNotifier
+	Publish(event, domain, file string, payload any)
+	Subscribe(lastID uint64) (replay []*models.StreamEvent, ch <-chan *models.StreamEvent, cancel func())
+}
+
+type RecordIndex interface {
+	Update(domain, file string, records []*models.AdsRecord, seenAt time.Time)
+	Query(q index.Query) []*models.PublisherMatch
 }
 
 type Server struct {
-	cfg    *config.Config
+	cfg     *config.Config
 	cache   AdsCache
-	log    *logger.Logger
+	log     *logger.Logger
 	ft      AdsFetcher
-	parser AdsParser
-	rl     *middleware.RateLimiter
+	parser  AdsParser
+	rl      *middleware.RateLimiter
+	sellers SellersVerifier
+	index   RecordIndex
+	history storage.SnapshotStore
+	raw     storage.RawStore
+	crawler CrawlerStatus
+	domains DomainStore
+	hooks   WebhookRegistry
+	events  EventStream
+	// notifiers are told about every change detected by a refresh.
+	notifiers []ChangeNotifier
+
+	// inflight coalesces concurrent cache misses for the same file.
+	inflight coalesce.Group[*models.AdsResponse]
+}
+
+// Option wires an optional subsystem into the Server.
+type Option func(*Server)
+
+// WithSellersVerifier enables sellers.json verification of returned records.
+func WithSellersVerifier(v SellersVerifier) Option {
+	return func(s *Server) {
+		s.sellers = v
+	}
+}
+
+// WithRecordIndex keeps ix up to date with every fetched file and enables
+// the GET /sellers reverse lookup.
+func WithRecordIndex(ix RecordIndex) Option {
+	return func(s *Server) {
+		s.index = ix
+	}
+}
+
+// WithCrawlerStatus exposes the background crawler's progress on
+// GET /admin/crawler.
+func WithCrawlerStatus(c CrawlerStatus) Option {
+	return func(s *Server) {
+		s.crawler = c
+	}
+}
+
+// WithDomainStore enables the tracked domain management endpoints.
+func WithDomainStore(ds DomainStore) Option {
+	return func(s *Server) {
+		s.domains = ds
+	}
+}
+
+// WithChangeNotifier has n told whenever a refresh finds a different record
+// set than the latest snapshot. It needs WithSnapshotStore and may be given
+// more than once.
+func WithChangeNotifier(n ChangeNotifier) Option {
+	return func(s *Server) {
+		s.notifiers = append(s.notifiers, n)
+	}
+}
+
+// WithWebhookRegistry enables the webhook registration endpoints.
+func WithWebhookRegistry(reg WebhookRegistry) Option {
+	return func(s *Server) {
+		s.hooks = reg
+	}
+}
+
+// WithEventStream publishes fetches, cache refreshes and changes to es and
+// enables GET /events.
+func WithEventStream(es EventStream) Option {
+	return func(s *Server) {
+		s.events = es
+		s.notifiers = append(s.notifiers, es)
+	}
+}
+
+// WithSnapshotStore stores a snapshot of every fetched file and enables
+// GET /ads/history.
+func WithSnapshotStore(st storage.SnapshotStore) Option {
+	return func(s *Server) {
+		s.history = st
+	}
+}
+
+// WithRawStore keeps the exact bytes of every fetched file and enables
+// GET /ads/raw.
+func WithRawStore(st storage.RawStore) Option {
+	return func(s *Server) {
+		s.raw = st
+	}
 }
 
 func NewServer(
@@ -50,10 +194,11 @@ func NewServer(
 	log *logger.Logger,
 	ft *fetcher.Fetcher,
 	parser *parser.Parser,
+	opts ...Option,
 ) *Server {
 	rl := middleware.NewRateLimiter(cfg.LimiterMaxReq, time.Duration(cfg.LimmiterTTL)*time.Second, log)
 
-	return &Server{
+	s := &Server{
 		cfg:    cfg,
 		cache:  adsCache,
 		log:    log,
@@ -61,52 +206,402 @@ func NewServer(
 		parser: parser,
 		rl:     rl,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Server) Router() http.Handler {
 	r := mux.NewRouter()
 
 	r.Handle("/ads", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.GetAds))).Methods(http.MethodGet)
-    r.HandleFunc("/health", s.Health).Methods(http.MethodGet)
+	r.Handle("/ads/validate", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.ValidateAds))).Methods(http.MethodGet)
+	r.Handle("/ads/validate", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.ValidateUpload))).Methods(http.MethodPost)
+	r.Handle("/ads/batch", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.BatchAds))).Methods(http.MethodPost)
+	if s.history != nil {
+		r.Handle("/ads/history", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.GetHistory))).Methods(http.MethodGet)
+		r.Handle("/ads/diff", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.GetDiff))).Methods(http.MethodGet)
+	}
+	if s.raw != nil {
+		r.Handle("/ads/raw", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.GetRaw))).Methods(http.MethodGet)
+	}
+	if s.domains != nil {
+		r.Handle("/domains", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.ListDomains))).Methods(http.MethodGet)
+		r.Handle("/domains", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.PutDomain))).Methods(http.MethodPost)
+		r.Handle("/domains/{domain}", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.GetDomain))).Methods(http.MethodGet)
+		r.Handle("/domains/{domain}", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.DeleteDomain))).Methods(http.MethodDelete)
+	}
+	if s.hooks != nil {
+		r.Handle("/webhooks", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.ListWebhooks))).Methods(http.MethodGet)
+		r.Handle("/webhooks", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.AddWebhook))).Methods(http.MethodPost)
+		r.Handle("/webhooks/{id}", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.DeleteWebhook))).Methods(http.MethodDelete)
+	}
+	if s.events != nil {
+		r.Handle("/events", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.StreamEvents))).Methods(http.MethodGet)
+	}
+	if s.index != nil {
+		r.Handle("/sellers", s.rl.RateLimitMiddleware()(http.HandlerFunc(s.SearchSellers))).Methods(http.MethodGet)
+	}
+	r.HandleFunc("/health", s.Health).Methods(http.MethodGet)
+	if s.crawler != nil {
+		r.HandleFunc("/admin/crawler", s.GetCrawlerStatus).Methods(http.MethodGet)
+	}
 
-	return r
-}
+	r.NotFoundHandler = http.HandlerFunc(apierror.NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(apierror.MethodNotAllowed)
 
+	return middleware.RequestID(r)
+}
 
 func (s *Server) Health(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, map[string]string{"status": "ok"})
 }
 
+func (s *Server) GetCrawlerStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, s.crawler.Status())
+}
+
 func (s *Server) GetAds(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	domain := strings.TrimSpace(r.URL.Query().Get("domain"))
-	if domain == "" {
-		http.Error(w, "missing domain", http.StatusBadRequest)
+	domain, ok := domainParam(w, r)
+	if !ok {
+		return
+	}
+	file, ok := fileParam(w, r)
+	if !ok {
 		return
 	}
 
-	if !isValidDomain(domain) {
-		http.Error(w, "invalid domain", http.StatusBadRequest)
+	verify, ok := s.verifyParam(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := s.lookup(ctx, domain, file)
+	if err != nil {
+		if ctx.Err() != nil {
+			s.log.Infow("Client went away while waiting for fetch", "domain", domain, "file", file)
+			return
+		}
+		writeFetchError(w, fetcher.Classify(err), file, domain)
+		return
+	}
+
+	if verify {
+		resp = s.verifySellers(ctx, resp)
+	}
+	writeJSON(w, resp)
+}
+
+// lookup returns a domain's file from the cache, or through a shared refresh
+// on a miss. Stale entries are returned while being revalidated in the
+// background. A cached failure comes back as a *fetcher.Error of its kind.
+func (s *Server) lookup(ctx context.Context, domain, file string) (*models.AdsResponse, error) {
+	key := cache.AdsKey(file, domain)
+	if cached, found := s.cache.GetAds(ctx, key); found {
+		s.log.Infow("Cache hit", "domain", domain, "file", file, "stale", cached.Stale)
+		cached.Cached = true
+		// Cheap when already indexed; fills the index after a restart.
+		s.indexResponse(cached)
+		if cached.Stale {
+			// A recently failed refresh is not retried until its negative
+			// entry expires; the stale copy is served meanwhile.
+			if _, failed := s.cache.GetFailure(ctx, key); !failed {
+				s.revalidate(ctx, domain, file)
+			}
+		}
+		return cached, nil
+	}
+
+	if failure, found := s.cache.GetFailure(ctx, key); found {
+		s.log.Infow("Negative cache hit", "domain", domain, "file", file, "kind", failure.Kind)
+		return nil, &fetcher.Error{Kind: failure.Kind, Err: errors.New(failure.Message)}
+	}
+
+	resp, err, shared := s.inflight.Do(ctx, key, func(ctx context.Context) (*models.AdsResponse, error) {
+		return s.refresh(ctx, domain, file)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		s.log.Debugw("Served from shared in-flight fetch", "domain", domain, "file", file)
+	}
+	return resp, nil
+}
+
+// revalidate refreshes a stale entry in the background while the stale copy
+// is served. A failed refresh leaves the stale entry in place, so it keeps
+// being served until its hard expiry.
+func (s *Server) revalidate(ctx context.Context, domain, file string) {
+	key := cache.AdsKey(file, domain)
+	if s.inflight.InFlight(key) {
+		return
+	}
+	go s.inflight.Do(context.WithoutCancel(ctx), key, func(ctx context.Context) (*models.AdsResponse, error) {
+		return s.refresh(ctx, domain, file)
+	})
+}
+
+// Refresh fetches a domain's file and stores the result as a cache miss in
+// GetAds would, sharing any fetch already in flight for it. It lets the
+// background crawler keep the cache warm.
+func (s *Server) Refresh(ctx context.Context, domain, file string) error {
+	_, err, _ := s.inflight.Do(ctx, cache.AdsKey(file, domain), func(ctx context.Context) (*models.AdsResponse, error) {
+		return s.refresh(ctx, domain, file)
+	})
+	return err
+}
+
+// refresh fetches and parses a domain's file and stores the result in the
+// cache. Callers go through s.inflight so only one refresh per file runs at
+// a time; ctx is therefore not tied to any single request.
+func (s *Server) refresh(ctx context.Context, domain, file string) (*models.AdsResponse, error) {
+	s.log.Infow("Fetching "+file, "domain", domain)
+	fetched, err := s.fetch(ctx, domain, file)
+	if err != nil {
+		s.log.Errorw("Failed to fetch "+file, zap.Error(err), "domain", domain)
+		s.cacheFailure(ctx, domain, file, err)
+		return nil, err
+	}
+
+	parsed := s.parser.ParseAdsTxt(strings.NewReader(fetched.Body))
+	resp := buildResponse(domain, file, fetched, parsed)
+	if file == models.FileAdsTxt {
+		resp.Subdomains = s.fetchSubdomains(ctx, domain, parsed.Variable(models.VariableSubdomain))
+	}
+	s.indexResponse(resp)
+	prev := s.previousVersion(ctx, domain, file, fetched.Body)
+	s.saveSnapshot(ctx, resp, fetched, parsed)
+
+	key := cache.AdsKey(file, domain)
+	if err := s.cache.SetAds(ctx, key, resp, s.cfg.CacheTTL); err != nil {
+		s.log.Warnw("Failed to cache "+file, zap.Error(err), "domain", domain)
+	} else {
+		s.publishCacheRefresh(resp)
+	}
+	if prev != nil {
+		s.detectChange(prev, resp)
+	}
+	return resp, nil
+}
+
+// cacheFailure stores a negative entry so that requests for a domain that
+// just failed are answered without another fetch until NegativeCacheTTL
+// passes.
+func (s *Server) cacheFailure(ctx context.Context, domain, file string, err error) {
+	if s.cfg.NegativeCacheTTL <= 0 || ctx.Err() != nil {
+		return
+	}
+	kind := fetcher.Classify(err)
+	failure := &models.FetchFailure{
+		Domain:    domain,
+		Type:      file,
+		Kind:      kind,
+		Message:   fetchErrorMessage(kind, file, domain),
+		Timestamp: time.Now().UTC(),
+	}
+	if err := s.cache.SetFailure(ctx, cache.AdsKey(file, domain), failure, s.cfg.NegativeCacheTTL); err != nil {
+		s.log.Warnw("Failed to cache fetch failure", zap.Error(err), "domain", domain)
+	}
+}
+
+// ValidateAds always fetches a fresh copy of the domain's ads.txt, since its
+// callers are usually checking whether a fix has been deployed.
+func (s *Server) ValidateAds(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	domain, ok := domainParam(w, r)
+	if !ok {
+		return
+	}
+	file, ok := fileParam(w, r)
+	if !ok {
 		return
 	}
 
-	if cached, found := s.cache.GetAds(ctx, domain); found {
-		s.log.Infow("Cache hit", "domain", domain)
-		writeJSON(w, cached)
+	s.log.Infow("Fetching "+file+" for validation", "domain", domain)
+	fetched, err := s.fetch(ctx, domain, file)
+	if err != nil {
+		s.log.Errorw("Failed to fetch "+file, zap.Error(err), "domain", domain)
+		writeFetchError(w, fetcher.Classify(err), file, domain)
 		return
 	}
 
-	s.log.Infow("Fetching ads.txt", "domain", domain)
-	content, err := s.ft.FetchAdsTxt(ctx, domain)
+	writeJSON(w, buildValidation(domain, file, fetched, s.parser.ParseAdsTxt(strings.NewReader(fetched.Body))))
+}
+
+// ValidateUpload validates an ads.txt body sent by the client, either as the
+// raw request body or as the "file" field of a multipart form. The optional
+// domain query parameter is only echoed back in the response.
+func (s *Server) ValidateUpload(w http.ResponseWriter, r *http.Request) {
+	domain := strings.TrimSpace(r.URL.Query().Get("domain"))
+	if domain != "" && !isValidDomain(domain) {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidDomain, "invalid domain", domain)
+		return
+	}
+	file, ok := fileParam(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	mediaType := "text/plain"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid content type", domain)
+			return
+		}
+		mediaType = mt
+	}
+
+	var body io.Reader
+	switch mediaType {
+	case "text/plain", "application/octet-stream":
+		body = r.Body
+	case "multipart/form-data":
+		upload, _, err := r.FormFile(uploadFormField)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidBody, fmt.Sprintf("missing %q file in multipart form", uploadFormField), domain)
+			return
+		}
+		defer upload.Close()
+		body = upload
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType, "unsupported content type, expected text/plain or multipart/form-data", domain)
+		return
+	}
+
+	content, err := io.ReadAll(body)
 	if err != nil {
-		s.log.Errorw("Failed to fetch ads.txt", zap.Error(err), "domain", domain)
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "ads.txt body too large", domain)
+			return
+		}
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidBody, "failed to read body", domain)
 		return
 	}
 
-	advertisersMap := s.parser.ParseAdsTxt(strings.NewReader(content))
-	advertisers := make([]*models.Advertiser, 0, len(advertisersMap))
-	for ad, count := range advertisersMap {
+	writeJSON(w, buildValidation(domain, file, nil, s.parser.ParseAdsTxt(bytes.NewReader(content))))
+}
+
+func (s *Server) fetch(ctx context.Context, domain, file string) (*fetcher.Result, error) {
+	start := time.Now()
+	var res *fetcher.Result
+	var err error
+	if file == models.FileAppAdsTxt {
+		res, err = s.ft.FetchAppAdsTxt(ctx, domain)
+	} else {
+		res, err = s.ft.FetchAdsTxt(ctx, domain)
+	}
+	s.publishFetch(domain, file, start, res, err)
+	if err == nil {
+		s.saveRaw(ctx, domain, file, res)
+	}
+	return res, err
+}
+
+// verifySellers returns a copy of resp whose records, including those of any
+// subdomain files, carry their sellers.json status. resp itself may be
+// shared with other requests and is left untouched. Annotations are never
+// stored in the ads cache, since sellers.json has its own cache lifetime.
+func (s *Server) verifySellers(ctx context.Context, resp *models.AdsResponse) *models.AdsResponse {
+	out := *resp
+	out.Records = cloneRecords(resp.Records)
+	records := out.Records
+
+	out.Subdomains = make([]*models.SubdomainResult, len(resp.Subdomains))
+	for i, sub := range resp.Subdomains {
+		cp := *sub
+		cp.Records = cloneRecords(sub.Records)
+		records = append(records, cp.Records...)
+		out.Subdomains[i] = &cp
+	}
+
+	s.sellers.Verify(ctx, records)
+	return &out
+}
+
+func cloneRecords(records []*models.AdsRecord) []*models.AdsRecord {
+	out := make([]*models.AdsRecord, len(records))
+	for i, rec := range records {
+		cp := *rec
+		out[i] = &cp
+	}
+	return out
+}
+
+// fetchSubdomains crawls the ads.txt files the root file points to with
+// SUBDOMAIN variables. Per the spec only subdomains of the root domain are
+// followed, and SUBDOMAIN lines inside those files are not.
+func (s *Server) fetchSubdomains(ctx context.Context, domain string, subdomains []string) []*models.SubdomainResult {
+	root := strings.TrimPrefix(domain, "www.")
+	seen := make(map[string]bool)
+	var targets []string
+	for _, sub := range subdomains {
+		sub = strings.ToLower(sub)
+		if seen[sub] || !strings.HasSuffix(sub, "."+root) || !isValidDomain(sub) {
+			continue
+		}
+		seen[sub] = true
+		targets = append(targets, sub)
+		if len(targets) == maxSubdomains {
+			break
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	out := make([]*models.SubdomainResult, len(targets))
+	var wg sync.WaitGroup
+	for i, sub := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i] = &models.SubdomainResult{Domain: sub}
+			// Through s.fetch so that subdomain files are kept and
+			// published like any other fetch.
+			fetched, err := s.fetch(ctx, sub, models.FileAdsTxt)
+			if err != nil {
+				s.log.Warnw("Failed to fetch subdomain ads.txt", zap.Error(err), "domain", domain, "subdomain", sub)
+				out[i].ErrorCode = fetcher.Classify(err)
+				out[i].Error = fetchErrorMessage(out[i].ErrorCode, models.FileAdsTxt, sub)
+				return
+			}
+			parsed := s.parser.ParseAdsTxt(strings.NewReader(fetched.Body))
+			out[i].SourceURL = fetched.URL
+			out[i].TotalRecords = len(parsed.Records)
+			out[i].Records = parsed.Records
+			out[i].Variables = parsed.Variables
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// buildValidation wraps the regular response with the parser diagnostics.
+func buildValidation(domain, file string, src *fetcher.Result, res *parser.Result) *models.ValidationResponse {
+	errs, warnings := res.Severities()
+	return &models.ValidationResponse{
+		AdsResponse: buildResponse(domain, file, src, res),
+		Valid:       errs == 0,
+		Errors:      errs,
+		Warnings:    warnings,
+		Diagnostics: res.Diagnostics,
+	}
+}
+
+// buildResponse converts parsed records into the API response, deriving the
+// per ad system counts from the full record list. src is nil when the file
+// was not fetched by the service.
+func buildResponse(domain, file string, src *fetcher.Result, res *parser.Result) *models.AdsResponse {
+	counts := res.Counts()
+	advertisers := make([]*models.Advertiser, 0, len(counts))
+	for ad, count := range counts {
 		advertisers = append(advertisers, &models.Advertiser{
 			Domain: ad,
 			Count:  count,
@@ -115,27 +610,95 @@ func (s *Server) GetAds(w http.ResponseWriter, r *http.Request) {
 
 	resp := &models.AdsResponse{
 		Domain:           domain,
-		TotalAdvertisers: len(advertisersMap),
+		Type:             file,
+		TotalAdvertisers: len(counts),
 		Advertisers:      advertisers,
+		TotalRecords:     len(res.Records),
+		Records:          res.Records,
+		Variables:        res.Variables,
 		Cached:           false,
 		Timestamp:        time.Now().UTC(),
 	}
-
-	s.cache.SetAds(ctx, domain, resp, s.cfg.CacheTTL)
-	writeJSON(w, resp)
+	if src != nil {
+		resp.SourceURL = src.URL
+		resp.RedirectChain = src.RedirectChain
+	}
+	return resp
 }
 
 func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(v); err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		// The status line is already out; all that is left is to log it.
 		logger.L().Errorw("Failed to encode JSON", zap.Error(err))
 	}
 }
 
+// domainParam reads and validates the domain query parameter, writing a 400
+// response when it is missing or malformed. The domain is lowercased, so
+// that every spelling of it shares one cache entry, history and webhook
+// subscription.
+func domainParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	domain := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("domain")))
+	if domain == "" {
+		writeError(w, http.StatusBadRequest, apierror.CodeMissingDomain, "missing domain", "")
+		return "", false
+	}
+
+	if !isValidDomain(domain) {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidDomain, "invalid domain", domain)
+		return "", false
+	}
+	return domain, true
+}
+
+// fileParam maps the optional type query parameter to the file to fetch:
+// "ads" (the default) for ads.txt, "app-ads" for app-ads.txt.
+func fileParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	file, ok := fileType(r.URL.Query().Get("type"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid type, must be 'ads' or 'app-ads'", "")
+	}
+	return file, ok
+}
+
+func fileType(raw string) (string, bool) {
+	switch strings.TrimSpace(raw) {
+	case "", "ads", models.FileAdsTxt:
+		return models.FileAdsTxt, true
+	case "app-ads", models.FileAppAdsTxt:
+		return models.FileAppAdsTxt, true
+	}
+	return "", false
+}
+
+// verifyParam reads the optional verify_sellers flag. It is rejected when
+// the server runs without a sellers.json verifier.
+func (s *Server) verifyParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	raw := r.URL.Query().Get("verify_sellers")
+	if raw == "" {
+		return false, true
+	}
+	verify, err := strconv.ParseBool(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid verify_sellers, must be a boolean", "")
+		return false, false
+	}
+	if verify && s.sellers == nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "sellers.json verification is not enabled", "")
+		return false, false
+	}
+	return verify, true
+}
+
 func isValidDomain(domain string) bool {
 	if len(domain) > 255 || len(domain) < 3 {
 		return false
 	}
 	return regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*\.[a-zA-Z]{2,}$`).MatchString(domain)
-}
\ No newline at end of file
+}
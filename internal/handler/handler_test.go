@@ -1,25 +1,40 @@
 package handler
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"ads-txt-service/internal/config"
+	"ads-txt-service/internal/domains"
+	"ads-txt-service/internal/events"
+	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/index"
 	"ads-txt-service/internal/logger"
 	"ads-txt-service/internal/middleware"
 	"ads-txt-service/internal/models"
+	"ads-txt-service/internal/parser"
+	"ads-txt-service/internal/storage"
+	"ads-txt-service/internal/webhook"
 )
 
-
 type mockAdsCache struct {
-	getFunc func(ctx context.Context, key string) (*models.AdsResponse, bool)
-	setFunc func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error
+	getFunc        func(ctx context.Context, key string) (*models.AdsResponse, bool)
+	setFunc        func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error
+	getFailureFunc func(ctx context.Context, key string) (*models.FetchFailure, bool)
+	setFailureFunc func(ctx context.Context, key string, f *models.FetchFailure, ttl time.Duration) error
 }
 
 func (m *mockAdsCache) GetAds(ctx context.Context, key string) (*models.AdsResponse, bool) {
@@ -30,19 +45,38 @@ func (m *mockAdsCache) SetAds(ctx context.Context, key string, resp *models.AdsR
 	return m.setFunc(ctx, key, resp, ttl)
 }
 
+func (m *mockAdsCache) GetFailure(ctx context.Context, key string) (*models.FetchFailure, bool) {
+	if m.getFailureFunc == nil {
+		return nil, false
+	}
+	return m.getFailureFunc(ctx, key)
+}
+
+func (m *mockAdsCache) SetFailure(ctx context.Context, key string, f *models.FetchFailure, ttl time.Duration) error {
+	if m.setFailureFunc == nil {
+		return nil
+	}
+	return m.setFailureFunc(ctx, key, f, ttl)
+}
+
 type mockAdsFetcher struct {
-	fetchFunc func(ctx context.Context, domain string) (string, error)
+	fetchFunc    func(ctx context.Context, domain string) (*fetcher.Result, error)
+	fetchAppFunc func(ctx context.Context, domain string) (*fetcher.Result, error)
 }
 
-func (m *mockAdsFetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+func (m *mockAdsFetcher) FetchAdsTxt(ctx context.Context, domain string) (*fetcher.Result, error) {
 	return m.fetchFunc(ctx, domain)
 }
 
+func (m *mockAdsFetcher) FetchAppAdsTxt(ctx context.Context, domain string) (*fetcher.Result, error) {
+	return m.fetchAppFunc(ctx, domain)
+}
+
 type mockAdsParser struct {
-	parseFunc func(r io.Reader) map[string]int
+	parseFunc func(r io.Reader) *parser.Result
 }
 
-func (m *mockAdsParser) ParseAdsTxt(r io.Reader) map[string]int {
+func (m *mockAdsParser) ParseAdsTxt(r io.Reader) *parser.Result {
 	return m.parseFunc(r)
 }
 
@@ -71,7 +105,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestServer_Health(t *testing.T) {
-	server := NewMockServer(nil, &mockAdsCache{}, logger.L(), &mockAdsFetcher{}, &mockAdsParser{})
+	server := NewMockServer(&config.Config{}, &mockAdsCache{}, logger.L(), &mockAdsFetcher{}, &mockAdsParser{})
 	router := server.Router()
 
 	req, err := http.NewRequest("GET", "/health", nil)
@@ -118,13 +152,15 @@ func TestServer_GetAds(t *testing.T) {
 			domain: "test.com",
 			setupMocks: func() {
 				mockC.getFunc = func(ctx context.Context, key string) (*models.AdsResponse, bool) {
-					return nil, false 
+					return nil, false
 				}
-				mockF.fetchFunc = func(ctx context.Context, domain string) (string, error) {
-					return "advertiser.com, pub-123, DIRECT\n", nil
+				mockF.fetchFunc = func(ctx context.Context, domain string) (*fetcher.Result, error) {
+					return &fetcher.Result{Body: "advertiser.com, pub-123, DIRECT\n"}, nil
 				}
-				mockP.parseFunc = func(r io.Reader) map[string]int {
-					return map[string]int{"advertiser.com": 1}
+				mockP.parseFunc = func(r io.Reader) *parser.Result {
+					return &parser.Result{Records: []*models.AdsRecord{
+						{AdSystem: "advertiser.com", PublisherID: "pub-123", Relationship: models.RelationshipDirect},
+					}}
 				}
 				mockC.setFunc = func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
 					return nil
@@ -140,39 +176,53 @@ func TestServer_GetAds(t *testing.T) {
 				mockC.getFunc = func(ctx context.Context, key string) (*models.AdsResponse, bool) {
 					return &models.AdsResponse{Domain: key, Cached: true}, true
 				}
-				
+
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `"domain":"cached.com","cached":true`,
+			expectedBody:   `"cached":true`,
 		},
 		{
 			name:           "Request with invalid domain",
 			domain:         "invalid-domain",
 			setupMocks:     func() {},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   "invalid domain\n",
+			expectedBody:   `{"code":"invalid_domain","message":"invalid domain","domain":"invalid-domain"`,
 		},
 		{
 			name:           "Missing domain parameter",
 			domain:         "",
 			setupMocks:     func() {},
 			expectedStatus: http.StatusBadRequest,
-			expectedBody:   "missing domain\n",
+			expectedBody:   `{"code":"missing_domain","message":"missing domain"`,
 		},
 		{
 			name:   "Fetcher returns an error",
 			domain: "fetcherror.com",
 			setupMocks: func() {
 				mockC.getFunc = func(ctx context.Context, key string) (*models.AdsResponse, bool) {
-					return nil, false 
+					return nil, false
 				}
-				mockF.fetchFunc = func(ctx context.Context, domain string) (string, error) {
-					return "", errors.New("failed to fetch") 
+				mockF.fetchFunc = func(ctx context.Context, domain string) (*fetcher.Result, error) {
+					return nil, errors.New("failed to fetch")
 				}
-				
+
 			},
 			expectedStatus: http.StatusBadGateway,
-			expectedBody:   "failed to fetch\n",
+			expectedBody:   `{"code":"connection_failure","message":"fetcherror.com is unreachable","domain":"fetcherror.com"`,
+		},
+		{
+			name:   "Publisher has no ads.txt",
+			domain: "missing.com",
+			setupMocks: func() {
+				mockC.getFunc = func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+					return nil, false
+				}
+				mockF.fetchFunc = func(ctx context.Context, domain string) (*fetcher.Result, error) {
+					return nil, &fetcher.Error{Kind: fetcher.KindNotFound, URL: "https://missing.com/ads.txt", StatusCode: 404}
+				}
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"code":"not_found","message":"missing.com has no ads.txt","domain":"missing.com"`,
 		},
 	}
 
@@ -193,3 +243,1160 @@ func TestServer_GetAds(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_ValidateAds(t *testing.T) {
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: "advertiser.com, pub-123, DIRECT\nadvertiser.com, pub-456\n"}, nil
+		},
+	}
+	cfg := &config.Config{LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, &mockAdsCache{}, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+
+	req, _ := http.NewRequest("GET", "/ads/validate?domain=test.com", nil)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var resp models.ValidationResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Valid || resp.Errors != 1 || resp.TotalRecords != 1 {
+		t.Errorf("unexpected validation summary: valid=%v errors=%d records=%d", resp.Valid, resp.Errors, resp.TotalRecords)
+	}
+	if len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Line != 2 || resp.Diagnostics[0].Reason != "missing relationship field" {
+		t.Errorf("unexpected diagnostics: %+v", resp.Diagnostics)
+	}
+}
+
+func TestServer_ValidateUpload(t *testing.T) {
+	cfg := &config.Config{LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, &mockAdsCache{}, logger.L(), &mockAdsFetcher{}, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	router := s.Router()
+
+	content := "advertiser.com, pub-123, DIRECT\nCONTACT=ops@test.com\nadvertiser.com, pub-456, OWNER\n"
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("file", "ads.txt")
+	fw.Write([]byte(content))
+	mw.Close()
+
+	testCases := []struct {
+		name           string
+		contentType    string
+		body           io.Reader
+		expectedStatus int
+	}{
+		{"Plain text body", "text/plain; charset=utf-8", strings.NewReader(content), http.StatusOK},
+		{"Multipart upload", mw.FormDataContentType(), bytes.NewReader(form.Bytes()), http.StatusOK},
+		{"Unsupported content type", "application/json", strings.NewReader(`{}`), http.StatusUnsupportedMediaType},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/ads/validate?domain=test.com", tc.body)
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatus)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp models.ValidationResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Domain != "test.com" || resp.TotalRecords != 1 || len(resp.Variables) != 1 || resp.Errors != 1 {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestServer_GetAppAds(t *testing.T) {
+	var cachedKey string
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			cachedKey = key
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchAppFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: "advertiser.com, pub-123, DIRECT\n", URL: "https://app.com/app-ads.txt"}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+
+	req, _ := http.NewRequest("GET", "/ads?domain=app.com&type=app-ads", nil)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), `"type":"app-ads.txt"`) {
+		t.Errorf("expected app-ads.txt response, got %q", rr.Body.String())
+	}
+	if cachedKey != "app-ads.txt:app.com" {
+		t.Errorf("expected app-ads.txt cache key, got %q", cachedKey)
+	}
+
+	req, _ = http.NewRequest("GET", "/ads?domain=app.com&type=bogus", nil)
+	rr = httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected invalid type to be rejected, got %v", rr.Code)
+	}
+}
+
+func TestServer_GetAdsSubdomains(t *testing.T) {
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			switch domain {
+			case "news.com":
+				return &fetcher.Result{Body: "advertiser.com, pub-1, DIRECT\nSUBDOMAIN=sport.news.com\nSUBDOMAIN=other.com\nSUBDOMAIN=down.news.com\n"}, nil
+			case "sport.news.com":
+				return &fetcher.Result{Body: "advertiser.com, pub-2, RESELLER\n", URL: "https://sport.news.com/ads.txt"}, nil
+			default:
+				return nil, errors.New("failed to fetch")
+			}
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+
+	req, _ := http.NewRequest("GET", "/ads?domain=news.com", nil)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	var resp models.AdsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Subdomains) != 2 {
+		t.Fatalf("expected 2 subdomain results, got %+v", resp.Subdomains)
+	}
+	if sub := resp.Subdomains[0]; sub.Domain != "sport.news.com" || sub.TotalRecords != 1 || sub.Records[0].PublisherID != "pub-2" {
+		t.Errorf("unexpected subdomain result: %+v", sub)
+	}
+	if sub := resp.Subdomains[1]; sub.Domain != "down.news.com" || sub.Error == "" {
+		t.Errorf("expected failed subdomain to carry an error: %+v", sub)
+	}
+}
+
+func TestServer_GetAdsCoalescesConcurrentMisses(t *testing.T) {
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			fetches.Add(1)
+			<-release
+			return &fetcher.Result{Body: "advertiser.com, pub-123, DIRECT\n"}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	router := s.Router()
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "/ads?domain=cnn.com", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			codes <- rr.Code
+		}()
+	}
+
+	for !s.inflight.InFlight("cnn.com") {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(codes)
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("expected a single outbound fetch, got %d", n)
+	}
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("expected every caller to get 200, got %d", code)
+		}
+	}
+}
+
+func TestServer_GetAdsServesStaleAndRevalidates(t *testing.T) {
+	refreshed := make(chan *models.AdsResponse, 1)
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return &models.AdsResponse{Domain: key, Stale: true, AgeSeconds: 600}, true
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			refreshed <- resp
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: "advertiser.com, pub-123, DIRECT\n"}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+
+	req, _ := http.NewRequest("GET", "/ads?domain=stale.com", nil)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"stale":true,"age_seconds":600`) {
+		t.Fatalf("expected the stale copy to be served, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	select {
+	case resp := <-refreshed:
+		if resp.Domain != "stale.com" || resp.TotalRecords != 1 {
+			t.Errorf("unexpected refreshed response: %+v", resp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a background refresh of the stale entry")
+	}
+}
+
+func TestServer_GetAdsNegativeCache(t *testing.T) {
+	var failure *models.FetchFailure
+	var fetches int
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		getFailureFunc: func(ctx context.Context, key string) (*models.FetchFailure, bool) {
+			return failure, failure != nil
+		},
+		setFailureFunc: func(ctx context.Context, key string, f *models.FetchFailure, ttl time.Duration) error {
+			if ttl != time.Minute {
+				t.Errorf("expected negative TTL to be used, got %v", ttl)
+			}
+			failure = f
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			fetches++
+			return nil, errors.New("dial tcp: connection refused")
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, NegativeCacheTTL: time.Minute, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	router := s.Router()
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "/ads?domain=dead.com", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("request %d: expected 502, got %d", i, rr.Code)
+		}
+	}
+
+	if fetches != 1 {
+		t.Errorf("expected repeated requests to be served from the negative cache, got %d fetches", fetches)
+	}
+	if failure == nil || failure.Kind != fetcher.KindConnection || failure.Domain != "dead.com" {
+		t.Errorf("unexpected cached failure: %+v", failure)
+	}
+}
+
+func TestServer_ErrorEnvelope(t *testing.T) {
+	// NewMockServer takes LimmiterTTL as a raw Duration; one token an hour
+	// keeps the second rate limited request from being refilled.
+	cfg := &config.Config{LimiterMaxReq: 1, LimmiterTTL: int(time.Hour)}
+	s := NewMockServer(cfg, &mockAdsCache{}, logger.L(), &mockAdsFetcher{}, &mockAdsParser{})
+	router := s.Router()
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) models.ErrorResponse {
+		t.Helper()
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("Content-Type = %q, want application/json", ct)
+		}
+		var e models.ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+			t.Fatalf("failed to decode error response %q: %v", rr.Body.String(), err)
+		}
+		return e
+	}
+
+	t.Run("request ID is echoed", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/ads?domain=bad", nil)
+		req.Header.Set("X-Request-ID", "abc-123")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		e := decode(t, rr)
+		if e.Code != "invalid_domain" || e.Domain != "bad" || e.RequestID != "abc-123" {
+			t.Errorf("unexpected error response: %+v", e)
+		}
+		if got := rr.Header().Get("X-Request-ID"); got != "abc-123" {
+			t.Errorf("X-Request-ID = %q, want abc-123", got)
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/ads/validate?domain=bad", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusTooManyRequests)
+		}
+		e := decode(t, rr)
+		if e.Code != "rate_limited" || e.RetryAfter <= 0 || e.RequestID == "" {
+			t.Errorf("unexpected error response: %+v", e)
+		}
+		if rr.Header().Get("Retry-After") == "" {
+			t.Error("expected a Retry-After header")
+		}
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/nope", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusNotFound)
+		}
+		if e := decode(t, rr); e.Code != "route_not_found" {
+			t.Errorf("unexpected error response: %+v", e)
+		}
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/health", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusMethodNotAllowed)
+		}
+		if e := decode(t, rr); e.Code != "method_not_allowed" {
+			t.Errorf("unexpected error response: %+v", e)
+		}
+	})
+}
+
+func TestServer_BatchAds(t *testing.T) {
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			if key == "cached.com" {
+				return &models.AdsResponse{Domain: "cached.com", Type: models.FileAdsTxt}, true
+			}
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	var fetches atomic.Int32
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			fetches.Add(1)
+			if domain == "missing.com" {
+				return nil, &fetcher.Error{Kind: fetcher.KindNotFound, StatusCode: 404}
+			}
+			return &fetcher.Result{Body: "advertiser.com, pub-123, DIRECT\n"}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60, BatchMaxDomains: 5, BatchConcurrency: 2}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	router := s.Router()
+
+	body := `{"domains": ["cached.com", "fresh.com", "missing.com", "not a domain", "Fresh.com", ""]}`
+
+	t.Run("buffered", func(t *testing.T) {
+		fetches.Store(0)
+		req, _ := http.NewRequest("POST", "/ads/batch", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rr.Code, rr.Body.String())
+		}
+
+		var resp models.BatchResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Total != 4 || resp.Succeeded != 2 || resp.Failed != 2 {
+			t.Fatalf("unexpected totals: %+v", resp)
+		}
+		want := []struct{ domain, code string }{
+			{"cached.com", ""},
+			{"fresh.com", ""},
+			{"missing.com", fetcher.KindNotFound},
+			{"not a domain", "invalid_domain"},
+		}
+		for i, w := range want {
+			res := resp.Results[i]
+			if res.Domain != w.domain {
+				t.Errorf("result %d: domain = %q, want %q", i, res.Domain, w.domain)
+			}
+			switch {
+			case w.code == "" && (res.Result == nil || res.Error != nil):
+				t.Errorf("result %d: expected a result, got error %+v", i, res.Error)
+			case w.code != "" && (res.Error == nil || res.Error.Code != w.code):
+				t.Errorf("result %d: expected error %q, got %+v", i, w.code, res.Error)
+			}
+		}
+		if !resp.Results[0].Result.Cached {
+			t.Error("expected cached.com to be served from the cache")
+		}
+		if n := fetches.Load(); n != 2 {
+			t.Errorf("expected 2 fetches, got %d", n)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/ads/batch?stream=true", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Fatalf("Content-Type = %q, want application/x-ndjson", ct)
+		}
+
+		seen := make(map[string]bool)
+		dec := json.NewDecoder(rr.Body)
+		for dec.More() {
+			var res models.BatchResult
+			if err := dec.Decode(&res); err != nil {
+				t.Fatalf("failed to decode line: %v", err)
+			}
+			seen[res.Domain] = true
+		}
+		if len(seen) != 4 {
+			t.Errorf("expected 4 streamed results, got %v", seen)
+		}
+	})
+
+	t.Run("too many domains", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/ads/batch", strings.NewReader(`{"domains": ["a.com", "b.com", "c.com", "d.com", "e.com", "f.com"]}`))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"code":"invalid_body"`) {
+			t.Errorf("unexpected response: %d %s", rr.Code, rr.Body.String())
+		}
+	})
+}
+
+func TestServer_SearchSellers(t *testing.T) {
+	files := map[string]string{
+		"a.com": "google.com, pub-123, DIRECT\nappnexus.com, 42, RESELLER\n",
+		"b.com": "google.com, pub-123, RESELLER\n",
+	}
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: files[domain]}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	s.index = index.New()
+	router := s.Router()
+
+	for domain := range files {
+		req, _ := http.NewRequest("GET", "/ads?domain="+domain, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET /ads for %s returned %d", domain, rr.Code)
+		}
+	}
+
+	testCases := []struct {
+		query          string
+		expectedStatus int
+		expectedTotal  int
+	}{
+		{"ad_system=google.com", http.StatusOK, 2},
+		{"ad_system=google.com&account_id=pub-123&relationship=direct", http.StatusOK, 1},
+		{"ad_system=appnexus.com&account_id=43", http.StatusOK, 0},
+		{"account_id=pub-123", http.StatusBadRequest, 0},
+		{"ad_system=google.com&relationship=owner", http.StatusBadRequest, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/sellers?"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var resp models.SellersResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Total != tc.expectedTotal || len(resp.Publishers) != tc.expectedTotal {
+				t.Errorf("got %d publishers, want %d", resp.Total, tc.expectedTotal)
+			}
+			for _, p := range resp.Publishers {
+				if p.LastSeen.IsZero() {
+					t.Errorf("expected last_seen to be set for %s", p.Domain)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_GetHistory(t *testing.T) {
+	bodies := []string{"google.com, pub-1, DIRECT\n", "google.com, pub-1, DIRECT\nappnexus.com, 7, RESELLER\n"}
+	var fetches int
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			body := bodies[fetches]
+			fetches++
+			return &fetcher.Result{Body: body, URL: "https://" + domain + "/ads.txt"}, nil
+		},
+	}
+	history, err := storage.NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	s.history = history
+	router := s.Router()
+
+	for range bodies {
+		req, _ := http.NewRequest("GET", "/ads?domain=example.com", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req, _ := http.NewRequest("GET", "/ads/history?domain=example.com", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rr.Code, rr.Body.String())
+	}
+	var list models.HistoryResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+	if list.Total != 2 || list.Snapshots[0].TotalRecords != 2 || list.Snapshots[1].TotalRecords != 1 {
+		t.Fatalf("expected two snapshots newest first, got %+v", list.Snapshots)
+	}
+
+	req, _ = http.NewRequest("GET", "/ads/history?domain=example.com&id="+list.Snapshots[1].ID, nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var snap models.Snapshot
+	if err := json.Unmarshal(rr.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+	if snap.Body != bodies[0] || snap.SourceURL != "https://example.com/ads.txt" {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+
+	req, _ = http.NewRequest("GET", "/ads/history?domain=example.com&id=20200101T000000.000000000Z", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `"code":"snapshot_not_found"`) {
+		t.Errorf("unexpected response for unknown snapshot: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestServer_GetDiff(t *testing.T) {
+	history, err := storage.NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	p := parser.NewParser()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	bodies := []string{
+		"google.com, pub-1, DIRECT\nappnexus.com, 7, DIRECT\n",
+		"google.com, pub-1, RESELLER\nappnexus.com, 7, DIRECT\n",
+		"google.com, pub-1, RESELLER\nopenx.com, 5, DIRECT\n",
+	}
+	var ids []string
+	for i, body := range bodies {
+		snap := &models.Snapshot{
+			SnapshotMeta: models.SnapshotMeta{Domain: "example.com", Type: models.FileAdsTxt, FetchedAt: t0.Add(time.Duration(i) * time.Hour)},
+			Body:         body,
+			Records:      p.ParseAdsTxt(strings.NewReader(body)).Records,
+		}
+		if err := history.Save(context.Background(), snap); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		ids = append(ids, snap.ID)
+	}
+
+	cfg := &config.Config{LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, &mockAdsCache{}, logger.L(), &mockAdsFetcher{}, &mockAdsParser{})
+	s.history = history
+	router := s.Router()
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		from, to       string
+		added, removed int
+		changed        int
+	}{
+		{"latest vs previous", "", http.StatusOK, ids[1], ids[2], 1, 1, 0},
+		{"explicit IDs", "&from=" + ids[0] + "&to=" + ids[1], http.StatusOK, ids[0], ids[1], 0, 0, 1},
+		{"points in time", "&from=2026-01-01T00:30:00Z&to=2026-01-01T05:00:00Z", http.StatusOK, ids[0], ids[2], 1, 1, 1},
+		{"before history", "&from=2025-01-01T00:00:00Z", http.StatusNotFound, "", "", 0, 0, 0},
+		{"nothing before first", "&to=" + ids[0], http.StatusNotFound, "", "", 0, 0, 0},
+		{"malformed", "&from=yesterday", http.StatusBadRequest, "", "", 0, 0, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/ads/diff?domain=example.com"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var resp models.DiffResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode diff: %v", err)
+			}
+			if resp.From.ID != tc.from || resp.To.ID != tc.to {
+				t.Errorf("compared %s..%s, want %s..%s", resp.From.ID, resp.To.ID, tc.from, tc.to)
+			}
+			if len(resp.Added) != tc.added || len(resp.Removed) != tc.removed || len(resp.Changed) != tc.changed {
+				t.Errorf("got %d added, %d removed, %d changed, want %d, %d, %d",
+					len(resp.Added), len(resp.Removed), len(resp.Changed), tc.added, tc.removed, tc.changed)
+			}
+		})
+	}
+}
+
+type staticCrawlerStatus struct {
+	status *models.CrawlerStatus
+}
+
+func (c *staticCrawlerStatus) Status() *models.CrawlerStatus {
+	return c.status
+}
+
+func TestServer_RefreshAndCrawlerStatus(t *testing.T) {
+	var stored *models.AdsResponse
+	mockC := &mockAdsCache{
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			stored = resp
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchAppFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: "google.com, pub-1, DIRECT\n"}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	s.crawler = &staticCrawlerStatus{status: &models.CrawlerStatus{Running: true, Workers: 4, Targets: 1, QueueDepth: 3}}
+
+	if err := s.Refresh(context.Background(), "example.com", models.FileAppAdsTxt); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if stored == nil || stored.Type != models.FileAppAdsTxt || stored.TotalRecords != 1 {
+		t.Errorf("expected the refreshed file to be cached, got %+v", stored)
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/crawler", nil)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"queue_depth":3`) {
+		t.Errorf("unexpected crawler status response: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestServer_Domains(t *testing.T) {
+	store, err := domains.NewStore(filepath.Join(t.TempDir(), "domains.json"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	cfg := &config.Config{LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, &mockAdsCache{}, logger.L(), &mockAdsFetcher{}, &mockAdsParser{})
+	s.domains = store
+	router := s.Router()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	testCases := []struct {
+		name           string
+		method, path   string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"create", "POST", "/domains", `{"domain": "MSN.com", "tags": {"region": "emea", "vertical": "news"}}`, http.StatusCreated, `"type":"ads.txt"`},
+		{"create app-ads", "POST", "/domains", `{"domain": "game.com", "type": "app-ads", "tags": {"region": "us"}, "crawl_interval_seconds": 600}`, http.StatusCreated, `"crawl_interval_seconds":600`},
+		{"update", "POST", "/domains", `{"domain": "msn.com", "tags": {"region": "emea"}}`, http.StatusOK, `"domain":"msn.com"`},
+		{"invalid domain", "POST", "/domains", `{"domain": "nope"}`, http.StatusBadRequest, `"code":"invalid_domain"`},
+		{"interval too short", "POST", "/domains", `{"domain": "msn.com", "crawl_interval_seconds": 5}`, http.StatusBadRequest, `"code":"invalid_parameter"`},
+		{"create other type", "POST", "/domains", `{"domain": "msn.com", "type": "app-ads", "tags": {"region": "us"}}`, http.StatusCreated, `"type":"app-ads.txt"`},
+		{"get", "GET", "/domains/msn.com", "", http.StatusOK, `"tags":{"region":"emea"}`},
+		{"get other type", "GET", "/domains/msn.com?type=app-ads", "", http.StatusOK, `"tags":{"region":"us"}`},
+		{"get invalid type", "GET", "/domains/msn.com?type=txt", "", http.StatusBadRequest, `"code":"invalid_parameter"`},
+		{"list by tag", "GET", "/domains?tag=region:us", "", http.StatusOK, `"total":2`},
+		{"list all", "GET", "/domains", "", http.StatusOK, `"total":3`},
+		{"delete wrong type", "DELETE", "/domains/game.com", "", http.StatusNotFound, `"code":"domain_not_found"`},
+		{"delete", "DELETE", "/domains/game.com?type=app-ads", "", http.StatusNoContent, ""},
+		{"get deleted", "GET", "/domains/game.com?type=app-ads", "", http.StatusNotFound, `"code":"domain_not_found"`},
+		{"delete unknown", "DELETE", "/domains/game.com?type=app-ads", "", http.StatusNotFound, `"code":"domain_not_found"`},
+		{"delete one type", "DELETE", "/domains/msn.com?type=app-ads", "", http.StatusNoContent, ""},
+		{"other type kept", "GET", "/domains/msn.com", "", http.StatusOK, `"type":"ads.txt"`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := do(tc.method, tc.path, tc.body)
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tc.expectedStatus, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), tc.expectedBody) {
+				t.Errorf("expected body to contain %q, got %q", tc.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []*models.ChangeEvent
+}
+
+func (n *recordingNotifier) Notify(ev *models.ChangeEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, ev)
+}
+
+func TestServer_DetectsChanges(t *testing.T) {
+	// Nothing ever stays cached: change detection must not depend on the
+	// cache entry surviving until the next refresh.
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	body := "google.com, pub-1, DIRECT\nappnexus.com, 7, DIRECT\n"
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: body}, nil
+		},
+	}
+	history, err := storage.NewDiskStore(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	s.history = history
+	n := &recordingNotifier{}
+	s.notifiers = []ChangeNotifier{n}
+	ctx := context.Background()
+
+	// The first fetch has nothing to compare with, and an identical
+	// re-fetch is not a change.
+	s.Refresh(ctx, "msn.com", models.FileAdsTxt)
+	s.Refresh(ctx, "msn.com", models.FileAdsTxt)
+	if len(n.events) != 0 {
+		t.Fatalf("expected no change events, got %d", len(n.events))
+	}
+
+	body = "google.com, pub-1, DIRECT\n"
+	s.Refresh(ctx, "msn.com", models.FileAdsTxt)
+	if len(n.events) != 1 {
+		t.Fatalf("expected one change event, got %d", len(n.events))
+	}
+	ev := n.events[0]
+	if ev.Event != models.EventAdsChanged || ev.Domain != "msn.com" || len(ev.Removed) != 1 || ev.Removed[0].AdSystem != "appnexus.com" || len(ev.Added) != 0 {
+		t.Errorf("unexpected change event: %+v", ev)
+	}
+	if ev.PreviousFetchedAt.IsZero() || ev.PreviousFetchedAt.After(ev.FetchedAt) {
+		t.Errorf("unexpected previous fetch time %v for a fetch at %v", ev.PreviousFetchedAt, ev.FetchedAt)
+	}
+
+	// A body that changes without changing the records is not reported.
+	body = "# comment\ngoogle.com, pub-1, DIRECT\n"
+	s.Refresh(ctx, "msn.com", models.FileAdsTxt)
+	if len(n.events) != 1 {
+		t.Errorf("expected no event for a change without record changes, got %d events", len(n.events))
+	}
+}
+
+// domainNotifier records the change events of one domain, the way a
+// webhook scoped to that domain receives them.
+type domainNotifier struct {
+	recordingNotifier
+	domain string
+}
+
+func (n *domainNotifier) Notify(ev *models.ChangeEvent) {
+	if ev.Domain == n.domain {
+		n.recordingNotifier.Notify(ev)
+	}
+}
+
+func TestServer_DetectsChangesWhateverTheDomainCase(t *testing.T) {
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	body := "google.com, pub-1, DIRECT\nappnexus.com, 7, DIRECT\n"
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: body}, nil
+		},
+	}
+	history, err := storage.NewDiskStore(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	s.history = history
+	n := &domainNotifier{domain: "cnn.com"}
+	s.notifiers = []ChangeNotifier{n}
+	router := s.Router()
+
+	req, _ := http.NewRequest("GET", "/ads?domain=cnn.com", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	body = "google.com, pub-1, DIRECT\n"
+	req, _ = http.NewRequest("GET", "/ads?domain=CNN.com", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rr.Code, rr.Body.String())
+	}
+	if len(n.events) != 1 {
+		t.Fatalf("expected the cnn.com notifier to get one change event, got %d", len(n.events))
+	}
+	if ev := n.events[0]; len(ev.Removed) != 1 || ev.Removed[0].AdSystem != "appnexus.com" {
+		t.Errorf("unexpected change event: %+v", ev)
+	}
+}
+
+func TestServer_Webhooks(t *testing.T) {
+	reg, err := webhook.NewRegistry(filepath.Join(t.TempDir(), "webhooks.json"))
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	cfg := &config.Config{LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, &mockAdsCache{}, logger.L(), &mockAdsFetcher{}, &mockAdsParser{})
+	s.hooks = reg
+	router := s.Router()
+
+	req, _ := http.NewRequest("POST", "/webhooks", strings.NewReader(`{"url": "https://ops.example.com/hook", "domains": ["MSN.com"]}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rr.Code, rr.Body.String())
+	}
+	var created models.Webhook
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ID == "" || created.Secret == "" || created.Domains[0] != "msn.com" {
+		t.Errorf("unexpected webhook: %+v", created)
+	}
+
+	for _, bad := range []string{"ftp://ops.example.com", "http://169.254.169.254/latest/meta-data", "http://localhost:8080/hook", "http://[::1]/hook", "https://10.0.0.7/hook"} {
+		req, _ = http.NewRequest("POST", "/webhooks", strings.NewReader(`{"url": "`+bad+`"}`))
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected, got %d", bad, rr.Code)
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "/webhooks", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `"total":1`) || strings.Contains(rr.Body.String(), created.Secret) {
+		t.Errorf("expected one webhook listed without its secret, got %s", rr.Body.String())
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		req, _ = http.NewRequest("DELETE", "/webhooks/"+created.ID, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("DELETE status = %d, want %d", rr.Code, want)
+		}
+	}
+}
+
+func TestServer_StreamEvents(t *testing.T) {
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: "google.com, pub-1, DIRECT\n", URL: "https://" + domain + "/ads.txt", StatusCode: 200}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60, EventsHeartbeat: time.Hour}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	broker := events.NewBroker(10, logger.L())
+	WithEventStream(broker)(s)
+	ts := httptest.NewServer(s.Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events?tag=region:emea")
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected tag filters without a domain store to be rejected, got %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events?domain=MSN.com", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	s.Refresh(context.Background(), "cnn.com", models.FileAdsTxt)
+	s.Refresh(context.Background(), "msn.com", models.FileAdsTxt)
+
+	lines := bufio.NewScanner(resp.Body)
+	var got []string
+	for len(got) < 2 && lines.Scan() {
+		if name, ok := strings.CutPrefix(lines.Text(), "event: "); ok {
+			got = append(got, name)
+		}
+		if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok && !strings.Contains(data, `"domain":"msn.com"`) {
+			t.Errorf("unexpected event for another domain: %s", data)
+		}
+	}
+	if len(got) != 2 || got[0] != models.EventFetchCompleted || got[1] != models.EventCacheRefreshed {
+		t.Errorf("unexpected events: %v", got)
+	}
+
+	// A reconnecting client resumes after the last event it saw.
+	replay, _, stop := broker.Subscribe(3)
+	stop()
+	if len(replay) != 1 || replay[0].Domain != "msn.com" || replay[0].Event != models.EventCacheRefreshed {
+		t.Errorf("unexpected replay: %+v", replay)
+	}
+}
+
+func TestServer_GetRaw(t *testing.T) {
+	rs, err := storage.NewDiskRawStore(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("NewDiskRawStore: %v", err)
+	}
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	// Bytes the parser would normalise away must come back untouched.
+	body := "\ufeffgoogle.com, pub-1, DIRECT \r\n\r\n#comment"
+	fetchedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{
+				Body:       body,
+				URL:        "https://" + domain + "/ads.txt",
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": {"text/html"}, "Server": {"nginx"}},
+				FetchedAt:  fetchedAt,
+			}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	s.raw = rs
+	router := s.Router()
+
+	s.Refresh(context.Background(), "msn.com", models.FileAdsTxt)
+	s.Refresh(context.Background(), "cnn.com", models.FileAdsTxt)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/ads/raw?"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("domain=msn.com")
+	if rr.Code != http.StatusOK || rr.Body.String() != body {
+		t.Fatalf("expected the original bytes, got %d %q", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	sha := rr.Header().Get("X-Raw-SHA256")
+	if rr.Header().Get("X-Raw-Source-URL") != "https://msn.com/ads.txt" || rr.Header().Get("X-Raw-Fetched-At") != "2026-03-01T12:00:00Z" || sha == "" {
+		t.Errorf("unexpected raw headers: %v", rr.Header())
+	}
+
+	rr = get("domain=cnn.com&meta=true&sha256=" + strings.ToUpper(sha))
+	var f models.RawFetch
+	json.Unmarshal(rr.Body.Bytes(), &f)
+	if rr.Code != http.StatusOK || f.SHA256 != sha || f.Header["Server"][0] != "nginx" || f.Size != len(body) {
+		t.Errorf("unexpected metadata: %d %s", rr.Code, rr.Body.String())
+	}
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"domain=yahoo.com", http.StatusNotFound},
+		{"domain=msn.com&at=2026-02-01T00:00:00Z", http.StatusNotFound},
+		{"domain=msn.com&at=2026-03-02T00:00:00Z", http.StatusOK},
+		{"domain=msn.com&at=yesterday", http.StatusBadRequest},
+		{"domain=msn.com&meta=maybe", http.StatusBadRequest},
+	} {
+		if rr := get(tc.query); rr.Code != tc.code {
+			t.Errorf("%s: status = %d, want %d", tc.query, rr.Code, tc.code)
+		}
+	}
+}
+
+func TestServer_GetRawSubdomain(t *testing.T) {
+	rs, err := storage.NewDiskRawStore(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("NewDiskRawStore: %v", err)
+	}
+	mockC := &mockAdsCache{
+		getFunc: func(ctx context.Context, key string) (*models.AdsResponse, bool) {
+			return nil, false
+		},
+		setFunc: func(ctx context.Context, key string, resp *models.AdsResponse, ttl time.Duration) error {
+			return nil
+		},
+	}
+	bodies := map[string]string{
+		"msn.com":      "google.com, pub-1, DIRECT\nsubdomain=news.msn.com\n",
+		"news.msn.com": "appnexus.com, 7, RESELLER\n",
+	}
+	mockF := &mockAdsFetcher{
+		fetchFunc: func(ctx context.Context, domain string) (*fetcher.Result, error) {
+			return &fetcher.Result{Body: bodies[domain], URL: "https://" + domain + "/ads.txt", StatusCode: 200}, nil
+		},
+	}
+	cfg := &config.Config{CacheTTL: time.Hour, LimiterMaxReq: 100, LimmiterTTL: 60}
+	s := NewMockServer(cfg, mockC, logger.L(), mockF, &mockAdsParser{})
+	s.parser = parser.NewParser()
+	s.raw = rs
+	broker := events.NewBroker(10, logger.L())
+	WithEventStream(broker)(s)
+	_, ch, stop := broker.Subscribe(0)
+
+	s.Refresh(context.Background(), "msn.com", models.FileAdsTxt)
+	stop()
+
+	req, _ := http.NewRequest("GET", "/ads/raw?domain=news.msn.com", nil)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != bodies["news.msn.com"] {
+		t.Errorf("expected the subdomain file to be stored, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	fetched := map[string]bool{}
+	for ev := range ch {
+		if ev.Event == models.EventFetchCompleted {
+			fetched[ev.Domain] = true
+		}
+	}
+	if !fetched["msn.com"] || !fetched["news.msn.com"] {
+		t.Errorf("expected fetch events for the domain and its subdomain, got %v", fetched)
+	}
+}
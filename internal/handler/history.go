@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"ads-txt-service/internal/apierror"
+	"ads-txt-service/internal/diff"
+	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/models"
+	"ads-txt-service/internal/parser"
+	"ads-txt-service/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// GetHistory lists the stored snapshots of a domain's file, newest first.
+// With an id parameter it returns that snapshot, including its raw body.
+func (s *Server) GetHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	domain, ok := domainParam(w, r)
+	if !ok {
+		return
+	}
+	file, ok := fileParam(w, r)
+	if !ok {
+		return
+	}
+
+	if id := strings.TrimSpace(r.URL.Query().Get("id")); id != "" {
+		snap, err := s.history.Get(ctx, domain, file, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, http.StatusNotFound, apierror.CodeSnapshotNotFound, "no snapshot "+id+" for "+domain, domain)
+			return
+		}
+		if err != nil {
+			s.log.Errorw("Failed to read snapshot", zap.Error(err), "domain", domain, "id", id)
+			writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to read snapshot", domain)
+			return
+		}
+		writeJSON(w, snap)
+		return
+	}
+
+	metas, err := s.history.List(ctx, domain, file)
+	if err != nil {
+		s.log.Errorw("Failed to list snapshots", zap.Error(err), "domain", domain)
+		writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to list snapshots", domain)
+		return
+	}
+	writeJSON(w, &models.HistoryResponse{Domain: domain, Type: file, Total: len(metas), Snapshots: metas})
+}
+
+// GetDiff compares two snapshots of a domain's file. from and to take a
+// snapshot ID or an RFC 3339 time, which selects the newest snapshot taken
+// at or before it. to defaults to the latest snapshot and from to the one
+// before to.
+func (s *Server) GetDiff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	domain, ok := domainParam(w, r)
+	if !ok {
+		return
+	}
+	file, ok := fileParam(w, r)
+	if !ok {
+		return
+	}
+
+	metas, err := s.history.List(ctx, domain, file)
+	if err != nil {
+		s.log.Errorw("Failed to list snapshots", zap.Error(err), "domain", domain)
+		writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to list snapshots", domain)
+		return
+	}
+
+	q := r.URL.Query()
+	to, ok := resolveSnapshot(w, metas, "to", q.Get("to"), 0, domain)
+	if !ok {
+		return
+	}
+	from, ok := resolveSnapshot(w, metas, "from", q.Get("from"), slices.Index(metas, to)+1, domain)
+	if !ok {
+		return
+	}
+
+	snaps := make([]*models.Snapshot, 2)
+	for i, meta := range []*models.SnapshotMeta{from, to} {
+		snaps[i], err = s.history.Get(ctx, domain, file, meta.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			// Pruned between listing and reading.
+			writeError(w, http.StatusNotFound, apierror.CodeSnapshotNotFound, "no snapshot "+meta.ID+" for "+domain, domain)
+			return
+		}
+		if err != nil {
+			s.log.Errorw("Failed to read snapshot", zap.Error(err), "domain", domain, "id", meta.ID)
+			writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to read snapshot", domain)
+			return
+		}
+	}
+
+	writeJSON(w, &models.DiffResponse{
+		Domain:     domain,
+		Type:       file,
+		From:       &snaps[0].SnapshotMeta,
+		To:         &snaps[1].SnapshotMeta,
+		RecordDiff: *diff.Records(snaps[0].Records, snaps[1].Records),
+	})
+}
+
+// resolveSnapshot finds the snapshot named by a from or to parameter in
+// metas, which is sorted newest first. An empty ref selects metas[def].
+func resolveSnapshot(w http.ResponseWriter, metas []*models.SnapshotMeta, param, ref string, def int, domain string) (*models.SnapshotMeta, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		if def >= len(metas) {
+			writeError(w, http.StatusNotFound, apierror.CodeSnapshotNotFound, "not enough snapshots of "+domain+" to compare", domain)
+			return nil, false
+		}
+		return metas[def], true
+	}
+
+	for _, m := range metas {
+		if m.ID == ref {
+			return m, true
+		}
+	}
+	at, err := time.Parse(time.RFC3339, ref)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid "+param+", must be a snapshot ID or an RFC 3339 time", domain)
+		return nil, false
+	}
+	for _, m := range metas {
+		if !m.FetchedAt.After(at) {
+			return m, true
+		}
+	}
+	writeError(w, http.StatusNotFound, apierror.CodeSnapshotNotFound, "no snapshot of "+domain+" at or before "+ref, domain)
+	return nil, false
+}
+
+// saveSnapshot records a fetch in the history. A failure only costs the
+// snapshot, so it is logged rather than returned.
+func (s *Server) saveSnapshot(ctx context.Context, resp *models.AdsResponse, fetched *fetcher.Result, parsed *parser.Result) {
+	if s.history == nil {
+		return
+	}
+	snap := &models.Snapshot{
+		SnapshotMeta: models.SnapshotMeta{
+			Domain:    resp.Domain,
+			Type:      resp.Type,
+			FetchedAt: resp.Timestamp,
+			SourceURL: fetched.URL,
+		},
+		Body:      fetched.Body,
+		Records:   parsed.Records,
+		Variables: parsed.Variables,
+	}
+	if err := s.history.Save(ctx, snap); err != nil {
+		s.log.Warnw("Failed to save snapshot", zap.Error(err), "domain", resp.Domain, "file", resp.Type)
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ads-txt-service/internal/apierror"
+	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/models"
+	"ads-txt-service/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// GetRaw returns the exact bytes a domain served for its file, byte for
+// byte, with the response's details in X-Raw-* headers. By default that is
+// the latest stored fetch; sha256 selects the latest fetch that returned
+// that body, and at (RFC 3339) the body being served at that time. With
+// meta=true the fetch's metadata, including the original response headers,
+// is returned as JSON instead.
+func (s *Server) GetRaw(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	domain, ok := domainParam(w, r)
+	if !ok {
+		return
+	}
+	file, ok := fileParam(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	meta := false
+	if raw := q.Get("meta"); raw != "" {
+		var err error
+		if meta, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid meta, must be a boolean", domain)
+			return
+		}
+	}
+
+	fetches, err := s.raw.Fetches(ctx, domain, file)
+	if err != nil {
+		s.log.Errorw("Failed to list raw fetches", zap.Error(err), "domain", domain)
+		writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to list raw fetches", domain)
+		return
+	}
+	f, ok := resolveRawFetch(w, fetches, strings.ToLower(strings.TrimSpace(q.Get("sha256"))), strings.TrimSpace(q.Get("at")), domain)
+	if !ok {
+		return
+	}
+	if meta {
+		writeJSON(w, f)
+		return
+	}
+
+	body, err := s.raw.Body(ctx, f.SHA256)
+	if errors.Is(err, storage.ErrBodyNotFound) {
+		writeError(w, http.StatusNotFound, apierror.CodeRawNotFound, "raw body "+f.SHA256+" is missing", domain)
+		return
+	}
+	if err != nil {
+		s.log.Errorw("Failed to read raw body", zap.Error(err), "domain", domain, "sha256", f.SHA256)
+		writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to read raw body", domain)
+		return
+	}
+
+	// Served as plain text whatever the publisher sent, so that no body can
+	// be rendered as a page; the original Content-Type is in the metadata.
+	h := w.Header()
+	h.Set("Content-Type", "text/plain")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	h.Set("ETag", `"`+f.SHA256+`"`)
+	h.Set("X-Raw-SHA256", f.SHA256)
+	h.Set("X-Raw-Source-URL", f.URL)
+	h.Set("X-Raw-Status", strconv.Itoa(f.StatusCode))
+	h.Set("X-Raw-Fetched-At", f.FetchedAt.Format(time.RFC3339))
+	h.Set("X-Raw-First-Fetched-At", f.FirstFetchedAt.Format(time.RFC3339))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// resolveRawFetch picks the fetch selected by the sha256 or at parameter
+// from fetches, which is sorted newest first.
+func resolveRawFetch(w http.ResponseWriter, fetches []*models.RawFetch, sha, at, domain string) (*models.RawFetch, bool) {
+	switch {
+	case sha != "":
+		for _, f := range fetches {
+			if f.SHA256 == sha {
+				return f, true
+			}
+		}
+		writeError(w, http.StatusNotFound, apierror.CodeRawNotFound, "no fetch of "+domain+" returned "+sha, domain)
+		return nil, false
+	case at != "":
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid at, must be an RFC 3339 time", domain)
+			return nil, false
+		}
+		for _, f := range fetches {
+			if !f.FirstFetchedAt.After(t) {
+				return f, true
+			}
+		}
+		writeError(w, http.StatusNotFound, apierror.CodeRawNotFound, "no raw body of "+domain+" at or before "+at, domain)
+		return nil, false
+	case len(fetches) == 0:
+		writeError(w, http.StatusNotFound, apierror.CodeRawNotFound, "no raw body stored for "+domain, domain)
+		return nil, false
+	}
+	return fetches[0], true
+}
+
+// saveRaw stores the exact bytes of a successful fetch. A failure only
+// costs the copy, so it is logged rather than returned.
+func (s *Server) saveRaw(ctx context.Context, domain, file string, res *fetcher.Result) {
+	if s.raw == nil {
+		return
+	}
+	f := &models.RawFetch{
+		Domain:        domain,
+		Type:          file,
+		URL:           res.URL,
+		RedirectChain: res.RedirectChain,
+		StatusCode:    res.StatusCode,
+		Header:        res.Header,
+		FetchedAt:     res.FetchedAt,
+	}
+	if f.FetchedAt.IsZero() {
+		f.FetchedAt = time.Now().UTC()
+	}
+	if err := s.raw.Save(ctx, f, []byte(res.Body)); err != nil {
+		s.log.Warnw("Failed to save raw body", zap.Error(err), "domain", domain, "file", file)
+	}
+}
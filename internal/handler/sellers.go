@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"ads-txt-service/internal/apierror"
+	"ads-txt-service/internal/index"
+	"ads-txt-service/internal/models"
+)
+
+// SearchSellers lists the publishers whose last fetched ads.txt or
+// app-ads.txt authorises the given ad system, optionally narrowed to one
+// seller account and relationship. Only domains the service has fetched are
+// known to the index.
+func (s *Server) SearchSellers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	adSystem := strings.ToLower(strings.TrimSpace(q.Get("ad_system")))
+	if adSystem == "" {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "missing ad_system", "")
+		return
+	}
+	if !isValidDomain(adSystem) {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid ad_system, must be a domain", "")
+		return
+	}
+
+	relationship := strings.ToUpper(strings.TrimSpace(q.Get("relationship")))
+	switch relationship {
+	case "", models.RelationshipDirect, models.RelationshipReseller:
+	default:
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid relationship, must be 'DIRECT' or 'RESELLER'", "")
+		return
+	}
+
+	query := index.Query{
+		AdSystem:     adSystem,
+		AccountID:    strings.TrimSpace(q.Get("account_id")),
+		Relationship: relationship,
+	}
+	matches := s.index.Query(query)
+	writeJSON(w, &models.SellersResponse{
+		AdSystem:     query.AdSystem,
+		AccountID:    query.AccountID,
+		Relationship: query.Relationship,
+		Total:        len(matches),
+		Publishers:   matches,
+	})
+}
+
+// indexResponse records a response's records, and those of its subdomain
+// files, in the reverse index.
+func (s *Server) indexResponse(resp *models.AdsResponse) {
+	if s.index == nil {
+		return
+	}
+	s.index.Update(resp.Domain, resp.Type, resp.Records, resp.Timestamp)
+	for _, sub := range resp.Subdomains {
+		if sub.ErrorCode == "" {
+			s.index.Update(sub.Domain, models.FileAdsTxt, sub.Records, resp.Timestamp)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ads-txt-service/internal/apierror"
+	"ads-txt-service/internal/diff"
+	"ads-txt-service/internal/models"
+	"ads-txt-service/internal/webhook"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// maxWebhookBodySize bounds the JSON body of a webhook registration.
+const maxWebhookBodySize = 64 << 10
+
+// AddWebhook registers a URL to be notified of changes. The secret used to
+// sign deliveries is generated when not given and is only ever returned in
+// this response.
+func (s *Server) AddWebhook(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxWebhookBodySize)
+	var h models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "webhook body too large", "")
+			return
+		}
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidBody, "invalid JSON body", "")
+		return
+	}
+
+	u, err := url.Parse(strings.TrimSpace(h.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid url, must be an absolute http or https URL", "")
+		return
+	}
+	if !s.cfg.WebhookAllowPrivateNetworks {
+		if err := webhook.CheckHost(r.Context(), u.Hostname()); err != nil {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidParameter, "invalid url, must not point at a loopback, link-local or private address", "")
+			return
+		}
+	}
+	h.URL = u.String()
+	for i, d := range h.Domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if !isValidDomain(d) {
+			writeError(w, http.StatusBadRequest, apierror.CodeInvalidDomain, "invalid domain in domains", d)
+			return
+		}
+		h.Domains[i] = d
+	}
+
+	if err := s.hooks.Add(&h); err != nil {
+		s.log.Errorw("Failed to save webhook", zap.Error(err), "url", h.URL)
+		writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to save webhook", "")
+		return
+	}
+	writeJSONStatus(w, http.StatusCreated, &h)
+}
+
+// ListWebhooks lists the registered webhooks without their secrets.
+func (s *Server) ListWebhooks(w http.ResponseWriter, _ *http.Request) {
+	hooks := s.hooks.List()
+	for _, h := range hooks {
+		h.Secret = ""
+	}
+	writeJSON(w, &models.WebhooksResponse{Total: len(hooks), Webhooks: hooks})
+}
+
+func (s *Server) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ok, err := s.hooks.Delete(id)
+	if err != nil {
+		s.log.Errorw("Failed to delete webhook", zap.Error(err), "webhook", id)
+		writeError(w, http.StatusInternalServerError, apierror.CodeInternal, "failed to delete webhook", "")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, apierror.CodeWebhookNotFound, "no webhook "+id, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// previousVersion returns the latest snapshot of a domain's file as the
+// baseline for change detection, or nil when there is none or body is the
+// same as the snapshot's. The history is used rather than the cache since
+// it outlives cache expiry, eviction and restarts.
+func (s *Server) previousVersion(ctx context.Context, domain, file, body string) *models.Snapshot {
+	if s.history == nil || len(s.notifiers) == 0 {
+		return nil
+	}
+	metas, err := s.history.List(ctx, domain, file)
+	if err != nil {
+		s.log.Warnw("Failed to list snapshots for change detection", zap.Error(err), "domain", domain)
+		return nil
+	}
+	if len(metas) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(body))
+	if metas[0].SHA256 == hex.EncodeToString(sum[:]) {
+		return nil
+	}
+	prev, err := s.history.Get(ctx, domain, file, metas[0].ID)
+	if err != nil {
+		s.log.Warnw("Failed to read snapshot for change detection", zap.Error(err), "domain", domain, "id", metas[0].ID)
+		return nil
+	}
+	return prev
+}
+
+// detectChange compares a fresh response with the previous version of the
+// file and tells the notifiers when the record set differs.
+func (s *Server) detectChange(prev *models.Snapshot, cur *models.AdsResponse) {
+	d := diff.Records(prev.Records, cur.Records)
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		return
+	}
+	s.log.Infow("Records changed", "domain", cur.Domain, "file", cur.Type,
+		"added", len(d.Added), "removed", len(d.Removed), "changed", len(d.Changed))
+
+	prevAt := prev.LastSeenAt
+	if prevAt.IsZero() {
+		prevAt = prev.FetchedAt
+	}
+	ev := &models.ChangeEvent{
+		Event:             models.EventAdsChanged,
+		Domain:            cur.Domain,
+		Type:              cur.Type,
+		PreviousFetchedAt: prevAt,
+		FetchedAt:         cur.Timestamp,
+		RecordDiff:        *d,
+	}
+	for _, n := range s.notifiers {
+		n.Notify(ev)
+	}
+}
@@ -0,0 +1,135 @@
+package index
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ads-txt-service/internal/models"
+)
+
+// Query selects records by ad system. AccountID and Relationship are
+// optional filters; an empty value matches anything.
+type Query struct {
+	AdSystem     string
+	AccountID    string
+	Relationship string
+}
+
+// doc is the latest known record set of one domain's file.
+type doc struct {
+	domain  string
+	file    string
+	records []*models.AdsRecord
+	seenAt  time.Time
+}
+
+// Index is an in-memory reverse index from ad systems to the publisher files
+// that list them. Each file is indexed with its latest record set only, so a
+// record dropped by the publisher disappears on the next update.
+type Index struct {
+	mu   sync.RWMutex
+	docs map[string]*doc
+	// bySystem maps an ad system to the keys of the docs listing it.
+	bySystem map[string]map[string]struct{}
+}
+
+func New() *Index {
+	return &Index{
+		docs:     make(map[string]*doc),
+		bySystem: make(map[string]map[string]struct{}),
+	}
+}
+
+// Update replaces the indexed records of a domain's file. Updates older than
+// what is already indexed are ignored, so replaying a cached response is
+// cheap and never rolls the index back.
+func (ix *Index) Update(domain, file string, records []*models.AdsRecord, seenAt time.Time) {
+	domain = strings.ToLower(domain)
+	key := file + ":" + domain
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if old, ok := ix.docs[key]; ok {
+		if !seenAt.After(old.seenAt) {
+			return
+		}
+		ix.unlinkLocked(key, old)
+	}
+
+	d := &doc{domain: domain, file: file, seenAt: seenAt, records: make([]*models.AdsRecord, 0, len(records))}
+	for _, rec := range records {
+		// Only the fields queried or returned are kept.
+		d.records = append(d.records, &models.AdsRecord{
+			AdSystem:     rec.AdSystem,
+			PublisherID:  rec.PublisherID,
+			Relationship: rec.Relationship,
+		})
+		systems, ok := ix.bySystem[rec.AdSystem]
+		if !ok {
+			systems = make(map[string]struct{})
+			ix.bySystem[rec.AdSystem] = systems
+		}
+		systems[key] = struct{}{}
+	}
+	ix.docs[key] = d
+}
+
+// Query returns one match per indexed record satisfying q, sorted by domain.
+func (ix *Index) Query(q Query) []*models.PublisherMatch {
+	adSystem := strings.ToLower(q.AdSystem)
+	relationship := strings.ToUpper(q.Relationship)
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	out := []*models.PublisherMatch{}
+	for key := range ix.bySystem[adSystem] {
+		d := ix.docs[key]
+		for _, rec := range d.records {
+			if rec.AdSystem != adSystem ||
+				(q.AccountID != "" && rec.PublisherID != q.AccountID) ||
+				(relationship != "" && rec.Relationship != relationship) {
+				continue
+			}
+			out = append(out, &models.PublisherMatch{
+				Domain:       d.domain,
+				Type:         d.file,
+				AdSystem:     rec.AdSystem,
+				PublisherID:  rec.PublisherID,
+				Relationship: rec.Relationship,
+				LastSeen:     d.seenAt,
+			})
+		}
+	}
+	slices.SortFunc(out, func(a, b *models.PublisherMatch) int {
+		return cmp.Or(
+			cmp.Compare(a.Domain, b.Domain),
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.PublisherID, b.PublisherID),
+			cmp.Compare(a.Relationship, b.Relationship),
+		)
+	})
+	return out
+}
+
+// Len returns the number of indexed files.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+func (ix *Index) unlinkLocked(key string, d *doc) {
+	for _, rec := range d.records {
+		systems := ix.bySystem[rec.AdSystem]
+		delete(systems, key)
+		if len(systems) == 0 {
+			delete(ix.bySystem, rec.AdSystem)
+		}
+	}
+	delete(ix.docs, key)
+}
@@ -0,0 +1,80 @@
+package index
+
+import (
+	"testing"
+	"time"
+
+	"ads-txt-service/internal/models"
+)
+
+func rec(adSystem, id, rel string) *models.AdsRecord {
+	return &models.AdsRecord{AdSystem: adSystem, PublisherID: id, Relationship: rel}
+}
+
+func TestIndex_Query(t *testing.T) {
+	ix := New()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ix.Update("b.com", models.FileAdsTxt, []*models.AdsRecord{
+		rec("google.com", "pub-123", models.RelationshipDirect),
+		rec("appnexus.com", "42", models.RelationshipReseller),
+	}, t0)
+	ix.Update("a.com", models.FileAdsTxt, []*models.AdsRecord{
+		rec("google.com", "pub-123", models.RelationshipReseller),
+		rec("google.com", "pub-999", models.RelationshipDirect),
+	}, t0)
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"ad system only", Query{AdSystem: "google.com"}, []string{"a.com", "a.com", "b.com"}},
+		{"account", Query{AdSystem: "GOOGLE.com", AccountID: "pub-123"}, []string{"a.com", "b.com"}},
+		{"account and relationship", Query{AdSystem: "google.com", AccountID: "pub-123", Relationship: "direct"}, []string{"b.com"}},
+		{"unknown ad system", Query{AdSystem: "rubicon.com"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ix.Query(tt.q)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d matches, want %d", len(got), len(tt.want))
+			}
+			for i, m := range got {
+				if m.Domain != tt.want[i] {
+					t.Errorf("match %d: domain = %q, want %q", i, m.Domain, tt.want[i])
+				}
+				if !m.LastSeen.Equal(t0) {
+					t.Errorf("match %d: last seen = %v, want %v", i, m.LastSeen, t0)
+				}
+			}
+		})
+	}
+}
+
+func TestIndex_Update(t *testing.T) {
+	ix := New()
+	t0 := time.Now()
+	ix.Update("a.com", models.FileAdsTxt, []*models.AdsRecord{rec("google.com", "pub-1", models.RelationshipDirect)}, t0)
+
+	// An older update, e.g. from a cached copy, must not roll the index back.
+	ix.Update("a.com", models.FileAdsTxt, nil, t0.Add(-time.Minute))
+	if got := ix.Query(Query{AdSystem: "google.com"}); len(got) != 1 {
+		t.Fatalf("expected stale update to be ignored, got %d matches", len(got))
+	}
+
+	t1 := t0.Add(time.Minute)
+	ix.Update("a.com", models.FileAdsTxt, []*models.AdsRecord{rec("appnexus.com", "7", models.RelationshipDirect)}, t1)
+	if got := ix.Query(Query{AdSystem: "google.com"}); len(got) != 0 {
+		t.Errorf("expected dropped record to be removed, got %d matches", len(got))
+	}
+	got := ix.Query(Query{AdSystem: "appnexus.com"})
+	if len(got) != 1 || !got[0].LastSeen.Equal(t1) {
+		t.Errorf("unexpected matches after update: %+v", got)
+	}
+
+	// ads.txt and app-ads.txt of the same domain are indexed separately.
+	ix.Update("a.com", models.FileAppAdsTxt, []*models.AdsRecord{rec("appnexus.com", "7", models.RelationshipDirect)}, t1)
+	if n := ix.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+}
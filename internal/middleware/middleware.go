@@ -1,12 +1,14 @@
 package middleware
 
 import (
+	"math"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"ads-txt-service/internal/apierror"
 	"ads-txt-service/internal/logger"
 	"ads-txt-service/internal/ratelimit"
 )
@@ -91,7 +93,9 @@ func (rl *RateLimiter) RateLimitMiddleware() func(http.Handler) http.Handler {
 			allowed, remaining := limiter.AllowWithRemaining()
 			if !allowed {
 				rl.log.Info("[ratelimit] BLOCK ip=%s remaining=%.2f\n", clientIP, remaining)
-				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				e := apierror.New(apierror.CodeRateLimited, "too many requests")
+				e.RetryAfter = rl.retryAfter(remaining)
+				apierror.Write(w, http.StatusTooManyRequests, e)
 				return
 			}
 			rl.log.Info("[ratelimit] ALLOW ip=%s remaining=%.2f\n", clientIP, remaining)
@@ -99,3 +103,13 @@ func (rl *RateLimiter) RateLimitMiddleware() func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// retryAfter estimates how many seconds until the client's bucket holds a
+// whole token again. Buckets refill capacity tokens every refillPeriod.
+func (rl *RateLimiter) retryAfter(remaining float64) int {
+	if rl.capacity <= 0 {
+		return 1
+	}
+	perToken := rl.refillPeriod.Seconds() / float64(rl.capacity)
+	return max(1, int(math.Ceil((1-remaining)*perToken)))
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"ads-txt-service/internal/apierror"
+)
+
+// maxRequestIDLength bounds client supplied IDs so they can't bloat logs.
+const maxRequestIDLength = 128
+
+// RequestID assigns every request an ID, reusing a client supplied
+// X-Request-ID when present, and echoes it on the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(apierror.RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+			r.Header.Set(apierror.RequestIDHeader, id)
+		}
+		w.Header().Set(apierror.RequestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
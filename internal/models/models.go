@@ -2,15 +2,375 @@ package models
 
 import "time"
 
+// Files the service knows how to fetch and parse. Both share the ads.txt
+// record format.
+const (
+	FileAdsTxt    = "ads.txt"
+	FileAppAdsTxt = "app-ads.txt"
+)
+
+const (
+	RelationshipDirect   = "DIRECT"
+	RelationshipReseller = "RESELLER"
+)
+
+// AdsRecord is a single data record of an ads.txt file as described by the
+// IAB ads.txt 1.1 specification.
+type AdsRecord struct {
+	AdSystem        string `json:"ad_system"`
+	PublisherID     string `json:"publisher_id"`
+	Relationship    string `json:"relationship"`
+	CertAuthorityID string `json:"cert_authority_id,omitempty"`
+	Extension       string `json:"extension,omitempty"`
+
+	Seller *SellerVerification `json:"seller,omitempty"`
+}
+
+// Outcomes of checking a record against the ad system's sellers.json.
+const (
+	SellerVerified    = "verified"
+	SellerMismatch    = "mismatch"
+	SellerNotFound    = "not_found"
+	SellerUnavailable = "unavailable"
+)
+
+// SellerVerification is the result of looking up a record's publisher
+// account ID in the sellers.json of its ad system.
+type SellerVerification struct {
+	Status     string `json:"status"`
+	SellerType string `json:"seller_type,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// Variables that may appear in an ads.txt file as <VARIABLE>=<VALUE> lines.
+const (
+	VariableContact       = "CONTACT"
+	VariableSubdomain     = "SUBDOMAIN"
+	VariableOwnerDomain   = "OWNERDOMAIN"
+	VariableManagerDomain = "MANAGERDOMAIN"
+	VariableInboundQuotes = "INBOUNDQUOTES"
+)
+
+// AdsVariable is a variable declaration line of an ads.txt file.
+type AdsVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic describes a problem found on a single line of an ads.txt file.
+type Diagnostic struct {
+	Line     int    `json:"line"`
+	Raw      string `json:"raw"`
+	Severity string `json:"severity"`
+	Reason   string `json:"reason"`
+}
+
+// SubdomainResult is an ads.txt file referenced by a SUBDOMAIN variable of
+// the root domain's file.
+type SubdomainResult struct {
+	Domain       string         `json:"domain"`
+	SourceURL    string         `json:"source_url,omitempty"`
+	TotalRecords int            `json:"total_records"`
+	Records      []*AdsRecord   `json:"records"`
+	Variables    []*AdsVariable `json:"variables"`
+	ErrorCode    string         `json:"error_code,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
 type Advertiser struct {
 	Domain string `json:"domain"`
 	Count  int    `json:"count"`
 }
 
 type AdsResponse struct {
-	Domain           string       `json:"domain"`
-	TotalAdvertisers int          `json:"total_advertisers"`
-	Advertisers      []*Advertiser `json:"advertisers"`
-	Cached           bool         `json:"cached"`
-	Timestamp        time.Time    `json:"timestamp"`
+	Domain           string             `json:"domain"`
+	Type             string             `json:"type"`
+	TotalAdvertisers int                `json:"total_advertisers"`
+	Advertisers      []*Advertiser      `json:"advertisers"`
+	TotalRecords     int                `json:"total_records"`
+	Records          []*AdsRecord       `json:"records"`
+	Variables        []*AdsVariable     `json:"variables"`
+	SourceURL        string             `json:"source_url,omitempty"`
+	RedirectChain    []string           `json:"redirect_chain,omitempty"`
+	Subdomains       []*SubdomainResult `json:"subdomains,omitempty"`
+	Cached           bool               `json:"cached"`
+	Stale            bool               `json:"stale"`
+	AgeSeconds       int64              `json:"age_seconds"`
+	Timestamp        time.Time          `json:"timestamp"`
+}
+
+// ValidationResponse is the parsed file together with the diagnostics
+// produced while parsing it.
+type ValidationResponse struct {
+	*AdsResponse
+	Valid       bool          `json:"valid"`
+	Errors      int           `json:"errors"`
+	Warnings    int           `json:"warnings"`
+	Diagnostics []*Diagnostic `json:"diagnostics"`
+}
+
+// FetchFailure records a failed fetch so that repeated requests for a dead
+// domain can be answered from the cache.
+type FetchFailure struct {
+	Domain    string    `json:"domain"`
+	Type      string    `json:"type"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ErrorResponse is the body of every non-2xx response of the API. Code is
+// stable and machine-readable: either one of the API's own codes
+// (missing_domain, rate_limited, ...) or, when the publisher's file could not
+// be fetched, the fetch failure kind (not_found, timeout, ...). Domain is set
+// when the error concerns a specific domain and RetryAfter, in seconds, when
+// the client should back off.
+type ErrorResponse struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Domain     string `json:"domain,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// BatchRequest is the body of a batch lookup. Type takes the same values as
+// the type query parameter of /ads.
+type BatchRequest struct {
+	Domains []string `json:"domains"`
+	Type    string   `json:"type,omitempty"`
+}
+
+// BatchResult is the outcome for one domain of a batch lookup. Exactly one
+// of Result and Error is set.
+type BatchResult struct {
+	Domain string         `json:"domain"`
+	Result *AdsResponse   `json:"result,omitempty"`
+	Error  *ErrorResponse `json:"error,omitempty"`
+}
+
+// BatchResponse collects the results of a batch lookup in request order.
+type BatchResponse struct {
+	Total     int            `json:"total"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Results   []*BatchResult `json:"results"`
+}
+
+// PublisherMatch is a publisher whose file lists the queried seller account.
+// LastSeen is the fetch time of the latest file that contained the record.
+type PublisherMatch struct {
+	Domain       string    `json:"domain"`
+	Type         string    `json:"type"`
+	AdSystem     string    `json:"ad_system"`
+	PublisherID  string    `json:"publisher_id"`
+	Relationship string    `json:"relationship"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// SellersResponse answers a reverse lookup of the publishers that authorise
+// an ad system, optionally narrowed to one account and relationship.
+type SellersResponse struct {
+	AdSystem     string            `json:"ad_system"`
+	AccountID    string            `json:"account_id,omitempty"`
+	Relationship string            `json:"relationship,omitempty"`
+	Total        int               `json:"total"`
+	Publishers   []*PublisherMatch `json:"publishers"`
+}
+
+// SnapshotMeta describes one stored version of a domain's file, first
+// fetched at FetchedAt and last at LastSeenAt. IDs sort in fetch order.
+type SnapshotMeta struct {
+	ID           string    `json:"id"`
+	Domain       string    `json:"domain"`
+	Type         string    `json:"type"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	SourceURL    string    `json:"source_url,omitempty"`
+	SHA256       string    `json:"sha256"`
+	Size         int       `json:"size"`
+	TotalRecords int       `json:"total_records"`
+}
+
+// RawFetch describes a stored raw body of a domain's file and the response
+// that served it. Consecutive fetches returning the same body from the same
+// URL share one entry, first seen at FirstFetchedAt and last at FetchedAt;
+// Header is that of the latest.
+type RawFetch struct {
+	Domain         string              `json:"domain"`
+	Type           string              `json:"type"`
+	SHA256         string              `json:"sha256"`
+	Size           int                 `json:"size"`
+	URL            string              `json:"url"`
+	RedirectChain  []string            `json:"redirect_chain,omitempty"`
+	StatusCode     int                 `json:"status_code"`
+	Header         map[string][]string `json:"header"`
+	FirstFetchedAt time.Time           `json:"first_fetched_at"`
+	FetchedAt      time.Time           `json:"fetched_at"`
+	Fetches        int                 `json:"fetches"`
+}
+
+// Snapshot is a stored fetch: the raw body together with what was parsed
+// from it.
+type Snapshot struct {
+	SnapshotMeta
+	Body      string         `json:"body"`
+	Records   []*AdsRecord   `json:"records"`
+	Variables []*AdsVariable `json:"variables"`
+}
+
+// HistoryResponse lists the stored snapshots of a domain's file, newest
+// first.
+type HistoryResponse struct {
+	Domain    string          `json:"domain"`
+	Type      string          `json:"type"`
+	Total     int             `json:"total"`
+	Snapshots []*SnapshotMeta `json:"snapshots"`
+}
+
+// RecordChange is a record whose ad system and publisher account ID stayed
+// the same while other fields, listed in Fields, changed.
+type RecordChange struct {
+	AdSystem    string     `json:"ad_system"`
+	PublisherID string     `json:"publisher_id"`
+	Fields      []string   `json:"fields"`
+	From        *AdsRecord `json:"from"`
+	To          *AdsRecord `json:"to"`
+}
+
+// RecordDiff is the difference between two record sets.
+type RecordDiff struct {
+	Added     []*AdsRecord    `json:"added"`
+	Removed   []*AdsRecord    `json:"removed"`
+	Changed   []*RecordChange `json:"changed"`
+	Unchanged int             `json:"unchanged"`
+}
+
+// DiffResponse compares two snapshots of a domain's file.
+type DiffResponse struct {
+	Domain string        `json:"domain"`
+	Type   string        `json:"type"`
+	From   *SnapshotMeta `json:"from"`
+	To     *SnapshotMeta `json:"to"`
+	RecordDiff
+}
+
+// CrawlTargetStatus is the crawler's view of one tracked file.
+type CrawlTargetStatus struct {
+	Domain    string     `json:"domain"`
+	Type      string     `json:"type"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	NextRun   time.Time  `json:"next_run"`
+	LastError string     `json:"last_error,omitempty"`
+	Successes int64      `json:"successes"`
+	Failures  int64      `json:"failures"`
+}
+
+// CrawlerStatus reports the background crawler's progress. QueueDepth is
+// the number of due files waiting for a worker.
+type CrawlerStatus struct {
+	Running         bool                 `json:"running"`
+	Workers         int                  `json:"workers"`
+	IntervalSeconds int64                `json:"interval_seconds"`
+	JitterSeconds   int64                `json:"jitter_seconds"`
+	Targets         int                  `json:"targets"`
+	QueueDepth      int                  `json:"queue_depth"`
+	InProgress      int                  `json:"in_progress"`
+	LastRun         *time.Time           `json:"last_run,omitempty"`
+	LastSourceError string               `json:"last_source_error,omitempty"`
+	Successes       int64                `json:"successes"`
+	Failures        int64                `json:"failures"`
+	Domains         []*CrawlTargetStatus `json:"domains"`
+}
+
+// TrackedDomain is a publisher domain registered for monitoring. Tags are
+// free-form key/value labels such as account manager, region or vertical.
+// A zero CrawlIntervalSeconds uses the crawler's default interval.
+type TrackedDomain struct {
+	Domain               string            `json:"domain"`
+	Type                 string            `json:"type"`
+	Tags                 map[string]string `json:"tags,omitempty"`
+	CrawlIntervalSeconds int64             `json:"crawl_interval_seconds,omitempty"`
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
+}
+
+// DomainsResponse lists tracked domains sorted by domain.
+type DomainsResponse struct {
+	Total   int              `json:"total"`
+	Domains []*TrackedDomain `json:"domains"`
+}
+
+// Event names. ads.changed is published when a refresh finds a different
+// record set; the others are only streamed on GET /events.
+const (
+	EventAdsChanged     = "ads.changed"
+	EventFetchCompleted = "fetch.completed"
+	EventCacheRefreshed = "cache.refreshed"
+)
+
+// ChangeEvent describes how a domain's records changed between the previous
+// version of its file, last fetched at PreviousFetchedAt, and a new fetch.
+type ChangeEvent struct {
+	Event             string    `json:"event"`
+	Domain            string    `json:"domain"`
+	Type              string    `json:"type"`
+	PreviousFetchedAt time.Time `json:"previous_fetched_at"`
+	FetchedAt         time.Time `json:"fetched_at"`
+	RecordDiff
+}
+
+// Webhook is a URL notified of change events. Domains, when set, limits the
+// events to those domains. Secret signs every delivery and is only returned
+// when the webhook is created.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Domains   []string  `json:"domains,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhooksResponse lists the registered webhooks.
+type WebhooksResponse struct {
+	Total    int        `json:"total"`
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+// FetchEvent reports a finished fetch of a domain's file. Error is set when
+// the fetch failed.
+type FetchEvent struct {
+	Event      string         `json:"event"`
+	Domain     string         `json:"domain"`
+	Type       string         `json:"type"`
+	URL        string         `json:"url,omitempty"`
+	StatusCode int            `json:"status_code,omitempty"`
+	DurationMS int64          `json:"duration_ms"`
+	Timestamp  time.Time      `json:"timestamp"`
+	Error      *ErrorResponse `json:"error,omitempty"`
+}
+
+// CacheEvent reports that a fresh copy of a domain's file was cached.
+type CacheEvent struct {
+	Event        string    `json:"event"`
+	Domain       string    `json:"domain"`
+	Type         string    `json:"type"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	TotalRecords int       `json:"total_records"`
+	TTLSeconds   int       `json:"ttl_seconds"`
+}
+
+// StreamEvent is one event of the GET /events stream. Data is its JSON
+// payload, encoded once when the event is published.
+type StreamEvent struct {
+	ID     uint64
+	Event  string
+	Domain string
+	Type   string
+	Data   []byte
 }
@@ -2,33 +2,215 @@ package parser
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"regexp"
 	"strings"
+
+	"ads-txt-service/internal/models"
 )
 
+var domainPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*\.[a-zA-Z]{2,}$`)
+
+var knownVariables = map[string]bool{
+	models.VariableContact:       true,
+	models.VariableSubdomain:     true,
+	models.VariableOwnerDomain:   true,
+	models.VariableManagerDomain: true,
+	models.VariableInboundQuotes: true,
+}
+
 type Parser struct{}
 
 func NewParser() *Parser {
 	return &Parser{}
 }
 
-func (p *Parser) ParseAdsTxt(r io.Reader) map[string]int {
+// Result holds everything extracted from a single ads.txt file.
+type Result struct {
+	Records     []*models.AdsRecord
+	Variables   []*models.AdsVariable
+	Diagnostics []*models.Diagnostic
+}
+
+// Variable returns the values declared for the given variable name.
+func (r *Result) Variable(name string) []string {
+	var out []string
+	for _, v := range r.Variables {
+		if v.Name == name {
+			out = append(out, v.Value)
+		}
+	}
+	return out
+}
+
+// Counts aggregates the parsed records by ad system domain.
+func (r *Result) Counts() map[string]int {
 	out := make(map[string]int)
+	for _, rec := range r.Records {
+		out[rec.AdSystem]++
+	}
+	return out
+}
+
+// Severities returns the number of error and warning diagnostics.
+func (r *Result) Severities() (errs, warnings int) {
+	for _, d := range r.Diagnostics {
+		switch d.Severity {
+		case models.SeverityError:
+			errs++
+		case models.SeverityWarning:
+			warnings++
+		}
+	}
+	return errs, warnings
+}
+
+// ParseAdsTxt parses an ads.txt file. Records that fail validation are
+// reported as diagnostics and left out of the result, as the spec asks
+// consumers to ignore invalid lines.
+func (p *Parser) ParseAdsTxt(r io.Reader) *Result {
+	res := &Result{
+		Records:     []*models.AdsRecord{},
+		Variables:   []*models.AdsVariable{},
+		Diagnostics: []*models.Diagnostic{},
+	}
 	scanner := bufio.NewScanner(r)
+	lineNo := 0
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
+		lineNo++
+		raw := scanner.Text()
+		if lineNo == 1 {
+			// Files saved by Windows editors often start with a UTF-8 BOM,
+			// which TrimSpace does not remove.
+			raw = strings.TrimPrefix(raw, "\ufeff")
+		}
+		line := stripComment(raw)
+		if line == "" {
 			continue
 		}
-		parts := strings.Split(line, ",")
-		if len(parts) == 0 {
+
+		report := func(severity, reason string) {
+			res.Diagnostics = append(res.Diagnostics, &models.Diagnostic{
+				Line:     lineNo,
+				Raw:      strings.TrimRight(raw, "\r"),
+				Severity: severity,
+				Reason:   reason,
+			})
+		}
+
+		if v := parseVariable(line); v != nil {
+			if severity, reason := validateVariable(v); reason != "" {
+				report(severity, reason)
+				if severity == models.SeverityError {
+					continue
+				}
+			}
+			res.Variables = append(res.Variables, v)
 			continue
 		}
-		ad := strings.TrimSpace(parts[0])
-		if ad == "" {
+
+		rec, reason := parseRecord(line)
+		if reason != "" {
+			report(models.SeverityError, reason)
 			continue
 		}
-		out[ad]++
+		res.Records = append(res.Records, rec)
 	}
-	return out
+	if err := scanner.Err(); err != nil {
+		res.Diagnostics = append(res.Diagnostics, &models.Diagnostic{
+			Line:     lineNo + 1,
+			Severity: models.SeverityError,
+			Reason:   fmt.Sprintf("unreadable line: %v", err),
+		})
+	}
+	return res
+}
+
+// parseRecord splits a data line into its fields:
+// <ad system domain>, <publisher account ID>, <relationship>[, <cert authority ID>][;<extension>]
+// A non-empty reason is returned when the line is not a valid record.
+func parseRecord(line string) (*models.AdsRecord, string) {
+	rec := &models.AdsRecord{}
+	if i := strings.Index(line, ";"); i >= 0 {
+		rec.Extension = strings.TrimSpace(line[i+1:])
+		line = line[:i]
+	}
+
+	parts := strings.Split(line, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	switch {
+	case len(parts) == 1:
+		return nil, "missing publisher account ID and relationship fields"
+	case len(parts) == 2:
+		return nil, "missing relationship field"
+	case len(parts) > 4:
+		return nil, fmt.Sprintf("too many fields: got %d, expected at most 4", len(parts))
+	}
+
+	rec.AdSystem = strings.ToLower(parts[0])
+	rec.PublisherID = parts[1]
+	rec.Relationship = strings.ToUpper(parts[2])
+	if len(parts) == 4 {
+		rec.CertAuthorityID = parts[3]
+	}
+
+	switch {
+	case rec.AdSystem == "":
+		return nil, "missing ad system domain"
+	case !domainPattern.MatchString(rec.AdSystem):
+		return nil, fmt.Sprintf("non-domain ad system %q", parts[0])
+	case rec.PublisherID == "":
+		return nil, "missing publisher account ID"
+	case rec.Relationship == "":
+		return nil, "missing relationship field"
+	case rec.Relationship != models.RelationshipDirect && rec.Relationship != models.RelationshipReseller:
+		return nil, fmt.Sprintf("invalid relationship value %q, expected DIRECT or RESELLER", parts[2])
+	}
+	return rec, ""
+}
+
+// parseVariable recognises <VARIABLE>=<VALUE> lines. A line is only treated
+// as a variable when the "=" comes before any record field separator.
+func parseVariable(line string) *models.AdsVariable {
+	i := strings.Index(line, "=")
+	if i <= 0 || strings.Contains(line[:i], ",") {
+		return nil
+	}
+	return &models.AdsVariable{
+		Name:  strings.ToUpper(strings.TrimSpace(line[:i])),
+		Value: strings.TrimSpace(line[i+1:]),
+	}
+}
+
+func validateVariable(v *models.AdsVariable) (severity, reason string) {
+	if v.Value == "" {
+		return models.SeverityError, fmt.Sprintf("empty value for variable %s", v.Name)
+	}
+	switch v.Name {
+	case models.VariableSubdomain, models.VariableOwnerDomain:
+		if !domainPattern.MatchString(v.Value) {
+			return models.SeverityError, fmt.Sprintf("%s value %q is not a domain", v.Name, v.Value)
+		}
+	case models.VariableManagerDomain:
+		// MANAGERDOMAIN=<domain>[,<country code>]
+		if d, _, _ := strings.Cut(v.Value, ","); !domainPattern.MatchString(strings.TrimSpace(d)) {
+			return models.SeverityError, fmt.Sprintf("%s value %q is not a domain", v.Name, v.Value)
+		}
+	default:
+		if !knownVariables[v.Name] {
+			return models.SeverityWarning, fmt.Sprintf("unknown variable %s", v.Name)
+		}
+	}
+	return "", ""
+}
+
+func stripComment(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
 }
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"ads-txt-service/internal/models"
+)
+
+func TestParser_ParseAdsTxt(t *testing.T) {
+	input := `# ads.txt for example.com
+google.com, pub-123, DIRECT, f08c47fec0942fa0
+Google.com, pub-456, reseller # inline comment
+appnexus.com, 1234, DIRECT;extension-data
+CONTACT=ads@example.com
+ownerdomain = example.com
+MANAGERDOMAIN=manager.com,US
+
+   
+`
+	res := NewParser().ParseAdsTxt(strings.NewReader(input))
+
+	want := []*models.AdsRecord{
+		{AdSystem: "google.com", PublisherID: "pub-123", Relationship: "DIRECT", CertAuthorityID: "f08c47fec0942fa0"},
+		{AdSystem: "google.com", PublisherID: "pub-456", Relationship: "RESELLER"},
+		{AdSystem: "appnexus.com", PublisherID: "1234", Relationship: "DIRECT", Extension: "extension-data"},
+	}
+	if len(res.Records) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(res.Records))
+	}
+	for i, rec := range res.Records {
+		if *rec != *want[i] {
+			t.Errorf("record %d: got %+v want %+v", i, *rec, *want[i])
+		}
+	}
+
+	counts := res.Counts()
+	if len(counts) != 2 || counts["google.com"] != 2 || counts["appnexus.com"] != 1 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+
+	wantVars := []models.AdsVariable{
+		{Name: models.VariableContact, Value: "ads@example.com"},
+		{Name: models.VariableOwnerDomain, Value: "example.com"},
+		{Name: models.VariableManagerDomain, Value: "manager.com,US"},
+	}
+	if len(res.Variables) != len(wantVars) {
+		t.Fatalf("expected %d variables, got %d", len(wantVars), len(res.Variables))
+	}
+	for i, v := range res.Variables {
+		if *v != wantVars[i] {
+			t.Errorf("variable %d: got %+v want %+v", i, *v, wantVars[i])
+		}
+	}
+	if got := res.Variable(models.VariableOwnerDomain); len(got) != 1 || got[0] != "example.com" {
+		t.Errorf("unexpected OWNERDOMAIN values: %v", got)
+	}
+}
+
+func TestParser_Diagnostics(t *testing.T) {
+	input := `google.com, pub-123, DIRECT
+google.com
+google.com, pub-123
+google.com, pub-123, OWNER
+not a domain, pub-123, DIRECT
+google.com, pub-123, DIRECT, abc, extra
+google.com, , DIRECT
+OWNERDOMAIN=
+FOO=bar
+`
+	res := NewParser().ParseAdsTxt(strings.NewReader(input))
+
+	want := []struct {
+		line     int
+		severity string
+		reason   string
+	}{
+		{2, models.SeverityError, "missing publisher account ID and relationship fields"},
+		{3, models.SeverityError, "missing relationship field"},
+		{4, models.SeverityError, `invalid relationship value "OWNER", expected DIRECT or RESELLER`},
+		{5, models.SeverityError, `non-domain ad system "not a domain"`},
+		{6, models.SeverityError, "too many fields: got 5, expected at most 4"},
+		{7, models.SeverityError, "missing publisher account ID"},
+		{8, models.SeverityError, "empty value for variable OWNERDOMAIN"},
+		{9, models.SeverityWarning, "unknown variable FOO"},
+	}
+	if len(res.Diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %+v", len(want), len(res.Diagnostics), res.Diagnostics)
+	}
+	for i, d := range res.Diagnostics {
+		if d.Line != want[i].line || d.Severity != want[i].severity || d.Reason != want[i].reason {
+			t.Errorf("diagnostic %d: got %+v want %+v", i, *d, want[i])
+		}
+	}
+
+	if len(res.Records) != 1 {
+		t.Errorf("expected invalid records to be skipped, got %d records", len(res.Records))
+	}
+	if len(res.Variables) != 1 || res.Variables[0].Name != "FOO" {
+		t.Errorf("expected only the unknown variable to be kept, got %+v", res.Variables)
+	}
+	if errs, warnings := res.Severities(); errs != 7 || warnings != 1 {
+		t.Errorf("unexpected severities: errors=%d warnings=%d", errs, warnings)
+	}
+}
+
+func TestParser_StripsBOM(t *testing.T) {
+	res := NewParser().ParseAdsTxt(strings.NewReader("\ufeffgoogle.com, pub-1, DIRECT\r\nappnexus.com, 7, RESELLER\r\n"))
+	if len(res.Records) != 2 || res.Records[0].AdSystem != "google.com" {
+		t.Fatalf("expected the BOM-prefixed first record to be kept, got %+v", res.Records)
+	}
+	if len(res.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %+v", res.Diagnostics[0])
+	}
+
+	// Only a leading BOM is a byte order mark.
+	res = NewParser().ParseAdsTxt(strings.NewReader("google.com, pub-1, DIRECT\n\ufeffappnexus.com, 7, RESELLER\n"))
+	if len(res.Records) != 1 {
+		t.Errorf("expected a BOM on a later line to invalidate it, got %+v", res.Records)
+	}
+}
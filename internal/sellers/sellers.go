@@ -0,0 +1,195 @@
+package sellers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ads-txt-service/internal/cache"
+	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// Seller types defined by the IAB sellers.json spec.
+const (
+	TypePublisher    = "PUBLISHER"
+	TypeIntermediary = "INTERMEDIARY"
+	TypeBoth         = "BOTH"
+)
+
+const (
+	// failureTTL is how long a sellers.json that could not be fetched or
+	// decoded is remembered before it is retried.
+	failureTTL = 10 * time.Minute
+	// workers bounds how many sellers.json files are fetched at once.
+	workers = 8
+)
+
+type SellersFetcher interface {
+	FetchSellersJSON(ctx context.Context, domain string) (*fetcher.Result, error)
+}
+
+// seller is the subset of a sellers.json entry needed for verification.
+type seller struct {
+	Type string `json:"t"`
+	Name string `json:"n,omitempty"`
+}
+
+// directory is what gets cached per ad system: the sellers indexed by ID, or
+// the reason they could not be loaded.
+type directory struct {
+	Sellers map[string]*seller `json:"s,omitempty"`
+	Error   string             `json:"e,omitempty"`
+}
+
+// sellersFile mirrors the parts of sellers.json we read. seller_id is kept
+// raw because some ad systems publish it as a number.
+type sellersFile struct {
+	Sellers []struct {
+		SellerID   json.RawMessage `json:"seller_id"`
+		SellerType string          `json:"seller_type"`
+		Name       string          `json:"name"`
+	} `json:"sellers"`
+}
+
+// Verifier checks ads.txt records against the sellers.json of their ad
+// systems. Parsed sellers.json files are kept in the cache so that each ad
+// system is fetched at most once per TTL.
+type Verifier struct {
+	ft    SellersFetcher
+	cache cache.Cache
+	ttl   time.Duration
+	log   *logger.Logger
+}
+
+func NewVerifier(ft SellersFetcher, c cache.Cache, ttl time.Duration, log *logger.Logger) *Verifier {
+	return &Verifier{ft: ft, cache: c, ttl: ttl, log: log}
+}
+
+// Verify annotates every record with the outcome of its sellers.json lookup.
+func (v *Verifier) Verify(ctx context.Context, records []*models.AdsRecord) {
+	var adSystems []string
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		if !seen[rec.AdSystem] {
+			seen[rec.AdSystem] = true
+			adSystems = append(adSystems, rec.AdSystem)
+		}
+	}
+
+	systems := make(map[string]*directory, len(adSystems))
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(adSystems)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for adSystem := range jobs {
+				dir := v.directory(ctx, adSystem)
+				mu.Lock()
+				systems[adSystem] = dir
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, adSystem := range adSystems {
+		jobs <- adSystem
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, rec := range records {
+		rec.Seller = check(rec, systems[rec.AdSystem])
+	}
+}
+
+func check(rec *models.AdsRecord, dir *directory) *models.SellerVerification {
+	if dir.Error != "" {
+		return &models.SellerVerification{Status: models.SellerUnavailable, Reason: dir.Error}
+	}
+
+	s, ok := dir.Sellers[rec.PublisherID]
+	if !ok {
+		return &models.SellerVerification{
+			Status: models.SellerNotFound,
+			Reason: fmt.Sprintf("seller_id %q not listed in %s/sellers.json", rec.PublisherID, rec.AdSystem),
+		}
+	}
+
+	out := &models.SellerVerification{Status: models.SellerVerified, SellerType: s.Type, Name: s.Name}
+	if !consistent(rec.Relationship, s.Type) {
+		out.Status = models.SellerMismatch
+		out.Reason = fmt.Sprintf("seller_type %s is inconsistent with %s relationship", s.Type, rec.Relationship)
+	}
+	return out
+}
+
+// consistent reports whether a seller_type may back the declared
+// relationship: DIRECT needs a publisher, RESELLER an intermediary.
+func consistent(relationship, sellerType string) bool {
+	switch relationship {
+	case models.RelationshipDirect:
+		return sellerType == TypePublisher || sellerType == TypeBoth
+	case models.RelationshipReseller:
+		return sellerType == TypeIntermediary || sellerType == TypeBoth
+	}
+	return false
+}
+
+func (v *Verifier) directory(ctx context.Context, adSystem string) *directory {
+	key := "sellers.json:" + adSystem
+	if b, err := v.cache.Get(ctx, key); err == nil && b != nil {
+		var dir directory
+		if err := json.Unmarshal(b, &dir); err == nil {
+			return &dir
+		}
+	}
+
+	dir, ttl := v.load(ctx, adSystem), v.ttl
+	if ctx.Err() != nil {
+		// The caller gave up; don't remember that as a broken sellers.json.
+		return dir
+	}
+	if dir.Error != "" {
+		ttl = min(ttl, failureTTL)
+	}
+	if b, err := json.Marshal(dir); err == nil {
+		if err := v.cache.Set(ctx, key, b, ttl); err != nil {
+			v.log.Warnw("Failed to cache sellers.json", zap.Error(err), "ad_system", adSystem)
+		}
+	}
+	return dir
+}
+
+func (v *Verifier) load(ctx context.Context, adSystem string) *directory {
+	v.log.Infow("Fetching sellers.json", "ad_system", adSystem)
+	res, err := v.ft.FetchSellersJSON(ctx, adSystem)
+	if err != nil {
+		v.log.Warnw("Failed to fetch sellers.json", zap.Error(err), "ad_system", adSystem)
+		return &directory{Error: "sellers.json could not be fetched"}
+	}
+
+	var file sellersFile
+	if err := json.Unmarshal([]byte(res.Body), &file); err != nil {
+		v.log.Warnw("Failed to decode sellers.json", zap.Error(err), "ad_system", adSystem)
+		return &directory{Error: "sellers.json is not valid JSON"}
+	}
+
+	dir := &directory{Sellers: make(map[string]*seller, len(file.Sellers))}
+	for _, s := range file.Sellers {
+		id := strings.TrimSpace(string(bytes.Trim(s.SellerID, `"`)))
+		if id == "" || id == "null" {
+			continue
+		}
+		dir.Sellers[id] = &seller{Type: strings.ToUpper(strings.TrimSpace(s.SellerType)), Name: s.Name}
+	}
+	return dir
+}
@@ -0,0 +1,90 @@
+package sellers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ads-txt-service/internal/fetcher"
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+)
+
+type mapCache struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+func (c *mapCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[key], nil
+}
+
+func (c *mapCache) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = data
+	return nil
+}
+
+func (c *mapCache) Del(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.m, key)
+	return nil
+}
+
+type fakeFetcher struct {
+	calls atomic.Int32
+	files map[string]string
+}
+
+func (f *fakeFetcher) FetchSellersJSON(ctx context.Context, domain string) (*fetcher.Result, error) {
+	f.calls.Add(1)
+	body, ok := f.files[domain]
+	if !ok {
+		return nil, errors.New("fetch failed")
+	}
+	return &fetcher.Result{Body: body}, nil
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	logger.Init("info")
+	ft := &fakeFetcher{files: map[string]string{
+		"exchange.com": `{"sellers":[
+			{"seller_id":"pub-1","seller_type":"PUBLISHER","name":"Example News"},
+			{"seller_id":"pub-2","seller_type":"INTERMEDIARY"},
+			{"seller_id":3,"seller_type":"BOTH"}
+		]}`,
+	}}
+	v := NewVerifier(ft, &mapCache{m: make(map[string][]byte)}, time.Hour, logger.L())
+
+	records := []*models.AdsRecord{
+		{AdSystem: "exchange.com", PublisherID: "pub-1", Relationship: models.RelationshipDirect},
+		{AdSystem: "exchange.com", PublisherID: "pub-2", Relationship: models.RelationshipDirect},
+		{AdSystem: "exchange.com", PublisherID: "3", Relationship: models.RelationshipReseller},
+		{AdSystem: "exchange.com", PublisherID: "pub-9", Relationship: models.RelationshipDirect},
+		{AdSystem: "down.com", PublisherID: "pub-1", Relationship: models.RelationshipDirect},
+	}
+	v.Verify(context.Background(), records)
+
+	want := []string{models.SellerVerified, models.SellerMismatch, models.SellerVerified, models.SellerNotFound, models.SellerUnavailable}
+	for i, rec := range records {
+		if rec.Seller == nil || rec.Seller.Status != want[i] {
+			t.Errorf("record %d: got %+v want status %s", i, rec.Seller, want[i])
+		}
+	}
+	if records[0].Seller.Name != "Example News" {
+		t.Errorf("expected seller name to be copied, got %q", records[0].Seller.Name)
+	}
+
+	calls := ft.calls.Load()
+	v.Verify(context.Background(), records)
+	if extra := ft.calls.Load() - calls; extra != 0 {
+		t.Errorf("expected sellers.json to be served from cache, got %d extra fetches", extra)
+	}
+}
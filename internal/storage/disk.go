@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"ads-txt-service/internal/fsutil"
+	"ads-txt-service/internal/models"
+)
+
+const (
+	snapshotExt = ".json"
+	// indexFile lists the metadata of a directory's snapshots so that
+	// listing does not have to read every body.
+	indexFile = "_index.json"
+)
+
+// DiskStore keeps snapshots as JSON files under
+// dir/<type>/<domain>/<id>.json, next to an index of their metadata. All
+// writes are atomic. A fetch returning the same body as the latest snapshot
+// only moves that snapshot's LastSeenAt, so the history holds distinct
+// versions. At most maxSnapshots are kept per domain and type; the oldest
+// are deleted first. A zero limit keeps everything.
+type DiskStore struct {
+	dir          string
+	maxSnapshots int
+
+	// mu serialises index updates. Snapshots are small in number per
+	// domain and written once per fetch, so one lock is enough.
+	mu sync.Mutex
+}
+
+func NewDiskStore(dir string, maxSnapshots int) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history dir: %w", err)
+	}
+	return &DiskStore{dir: dir, maxSnapshots: maxSnapshots}, nil
+}
+
+func (d *DiskStore) Save(_ context.Context, snap *models.Snapshot) error {
+	dir, err := d.domainDir(snap.Domain, snap.Type)
+	if err != nil {
+		return err
+	}
+	if snap.SHA256 == "" {
+		sum := sha256.Sum256([]byte(snap.Body))
+		snap.SHA256 = hex.EncodeToString(sum[:])
+	}
+	snap.Size = len(snap.Body)
+	snap.TotalRecords = len(snap.Records)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	metas, err := readIndex(dir)
+	if err != nil {
+		return fmt.Errorf("snapshot SAVE failed: %w", err)
+	}
+	if n := len(metas); n > 0 && metas[n-1].SHA256 == snap.SHA256 {
+		latest := metas[n-1]
+		if snap.FetchedAt.After(latest.LastSeenAt) {
+			latest.LastSeenAt = snap.FetchedAt
+		}
+		snap.SnapshotMeta = *latest
+		if err := writeIndex(dir, metas); err != nil {
+			return fmt.Errorf("snapshot SAVE failed: %w", err)
+		}
+		return nil
+	}
+
+	if snap.ID == "" {
+		snap.ID = NewID(snap.FetchedAt)
+	}
+	if snap.LastSeenAt.IsZero() {
+		snap.LastSeenAt = snap.FetchedAt
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("snapshot SAVE failed: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("snapshot SAVE failed: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, snap.ID+snapshotExt), b, 0o644); err != nil {
+		return fmt.Errorf("snapshot SAVE failed: %w", err)
+	}
+
+	meta := snap.SnapshotMeta
+	metas = slices.DeleteFunc(metas, func(m *models.SnapshotMeta) bool { return m.ID == meta.ID })
+	metas = append(metas, &meta)
+	slices.SortFunc(metas, func(a, b *models.SnapshotMeta) int { return strings.Compare(a.ID, b.ID) })
+
+	if d.maxSnapshots > 0 && len(metas) > d.maxSnapshots {
+		for _, old := range metas[:len(metas)-d.maxSnapshots] {
+			os.Remove(filepath.Join(dir, old.ID+snapshotExt))
+		}
+		metas = metas[len(metas)-d.maxSnapshots:]
+	}
+	if err := writeIndex(dir, metas); err != nil {
+		return fmt.Errorf("snapshot SAVE failed: %w", err)
+	}
+	return nil
+}
+
+func (d *DiskStore) List(_ context.Context, domain, file string) ([]*models.SnapshotMeta, error) {
+	dir, err := d.domainDir(domain, file)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	metas, err := readIndex(dir)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot LIST failed: %w", err)
+	}
+	slices.Reverse(metas)
+	return metas, nil
+}
+
+func (d *DiskStore) Get(_ context.Context, domain, file, id string) (*models.Snapshot, error) {
+	dir, err := d.domainDir(domain, file)
+	if err != nil {
+		return nil, err
+	}
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, id+snapshotExt))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot GET failed: %w", err)
+	}
+	var snap models.Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, fmt.Errorf("snapshot GET failed: %w", err)
+	}
+
+	// Only the index is updated when the snapshot is seen again.
+	d.mu.Lock()
+	metas, err := readIndex(dir)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot GET failed: %w", err)
+	}
+	for _, m := range metas {
+		if m.ID == id {
+			snap.SnapshotMeta = *m
+		}
+	}
+	return &snap, nil
+}
+
+func (d *DiskStore) domainDir(domain, file string) (string, error) {
+	return domainPath(d.dir, domain, file)
+}
+
+// domainPath returns root/<file>/<domain>, rejecting names that could
+// escape root. Callers validate domains already; this is the last line of
+// defence.
+func domainPath(root, domain, file string) (string, error) {
+	for _, name := range []string{domain, file} {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return "", fmt.Errorf("invalid store path element %q", name)
+		}
+	}
+	return filepath.Join(root, file, strings.ToLower(domain)), nil
+}
+
+func readIndex(dir string) ([]*models.SnapshotMeta, error) {
+	b, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []*models.SnapshotMeta{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var metas []*models.SnapshotMeta
+	if err := json.Unmarshal(b, &metas); err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+func writeIndex(dir string, metas []*models.SnapshotMeta) error {
+	b, err := json.Marshal(metas)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(dir, indexFile), b, 0o644)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"ads-txt-service/internal/models"
+)
+
+func snapshot(domain string, at time.Time, body string) *models.Snapshot {
+	return &models.Snapshot{
+		SnapshotMeta: models.SnapshotMeta{Domain: domain, Type: models.FileAdsTxt, FetchedAt: at},
+		Body:         body,
+		Records:      []*models.AdsRecord{{AdSystem: "google.com", PublisherID: "pub-1", Relationship: models.RelationshipDirect}},
+	}
+}
+
+func TestDiskStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ds, err := NewDiskStore(dir, 2)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		snap := snapshot("example.com", t0.Add(time.Duration(i)*time.Hour), fmt.Sprintf("google.com, pub-%d, DIRECT\n", i))
+		if err := ds.Save(ctx, snap); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		ids = append(ids, snap.ID)
+	}
+
+	// Reopening the directory must see the same history.
+	ds, err = NewDiskStore(dir, 2)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	metas, err := ds.List(ctx, "example.com", models.FileAdsTxt)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(metas) != 2 || metas[0].ID != ids[2] || metas[1].ID != ids[1] {
+		t.Fatalf("expected the two newest snapshots newest first, got %+v", metas)
+	}
+	if metas[0].SHA256 == "" || metas[0].TotalRecords != 1 || metas[0].Size == 0 {
+		t.Errorf("metadata not filled in: %+v", metas[0])
+	}
+
+	snap, err := ds.Get(ctx, "example.com", models.FileAdsTxt, ids[2])
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if snap.Body != "google.com, pub-2, DIRECT\n" || len(snap.Records) != 1 || !snap.FetchedAt.Equal(t0.Add(2*time.Hour)) {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+
+	if _, err := ds.Get(ctx, "example.com", models.FileAdsTxt, ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the oldest snapshot to be pruned, got %v", err)
+	}
+	if _, err := ds.Get(ctx, "example.com", models.FileAdsTxt, "../../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a malformed ID, got %v", err)
+	}
+	if metas, err := ds.List(ctx, "other.com", models.FileAdsTxt); err != nil || len(metas) != 0 {
+		t.Errorf("expected empty history for unknown domain, got %v, %v", metas, err)
+	}
+	if err := ds.Save(ctx, snapshot("../evil", t0, "")); err == nil {
+		t.Error("expected a domain with a path separator to be rejected")
+	}
+}
+
+func TestDiskStore_SkipsUnchangedBodies(t *testing.T) {
+	ctx := context.Background()
+	ds, err := NewDiskStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	bodies := []string{"a\n", "b\n", "b\n", "b\n"}
+	for i, body := range bodies {
+		if err := ds.Save(ctx, snapshot("example.com", t0.Add(time.Duration(i)*time.Hour), body)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	// Repeats of the latest body must not push the earlier version out.
+	metas, err := ds.List(ctx, "example.com", models.FileAdsTxt)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(metas) != 2 {
+		t.Fatalf("expected 2 distinct versions, got %+v", metas)
+	}
+	if !metas[0].FetchedAt.Equal(t0.Add(time.Hour)) || !metas[0].LastSeenAt.Equal(t0.Add(3*time.Hour)) {
+		t.Errorf("expected the latest version to span 01:00 to 03:00, got %+v", metas[0])
+	}
+	if !metas[1].LastSeenAt.Equal(t0) {
+		t.Errorf("expected the first version to be last seen at 00:00, got %+v", metas[1])
+	}
+
+	snap, err := ds.Get(ctx, "example.com", models.FileAdsTxt, metas[0].ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !snap.LastSeenAt.Equal(t0.Add(3 * time.Hour)) {
+		t.Errorf("expected Get to report the latest sighting, got %v", snap.LastSeenAt)
+	}
+
+	// A body going back to an earlier version is a new version.
+	ds.Save(ctx, snapshot("example.com", t0.Add(4*time.Hour), "a\n"))
+	metas, _ = ds.List(ctx, "example.com", models.FileAdsTxt)
+	if len(metas) != 2 || metas[0].Size != 2 || !metas[0].FetchedAt.Equal(t0.Add(4*time.Hour)) {
+		t.Errorf("expected a revert to be stored, got %+v", metas)
+	}
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"ads-txt-service/internal/fsutil"
+	"ads-txt-service/internal/models"
+)
+
+// ErrBodyNotFound is returned by RawStore.Body when no body has the
+// requested hash.
+var ErrBodyNotFound = errors.New("raw body not found")
+
+// RawStore keeps the exact bytes of fetched files, addressed by their
+// SHA-256, together with the responses that served them.
+type RawStore interface {
+	// Save stores body and records the fetch, filling in its SHA256 and
+	// Size.
+	Save(ctx context.Context, f *models.RawFetch, body []byte) error
+	// Fetches returns the recorded fetches of a domain's file, newest
+	// first.
+	Fetches(ctx context.Context, domain, file string) ([]*models.RawFetch, error)
+	// Body returns the bytes with the given hex SHA-256 or ErrBodyNotFound.
+	Body(ctx context.Context, sha string) ([]byte, error)
+}
+
+// DiskRawStore keeps each distinct body once, as dir/blobs/<xx>/<sha256>
+// where xx are the hash's first two hex digits, however many fetches of
+// however many domains returned it. The fetches of each domain and type are
+// logged in dir/fetches/<type>/<domain>.json. At most maxFetches log
+// entries are kept per domain and type, or all with a zero limit. Bodies
+// are never deleted, since other logs may still refer to them.
+type DiskRawStore struct {
+	dir        string
+	maxFetches int
+
+	// mu serialises fetch log updates.
+	mu sync.Mutex
+}
+
+func NewDiskRawStore(dir string, maxFetches int) (*DiskRawStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create raw store dir: %w", err)
+	}
+	return &DiskRawStore{dir: dir, maxFetches: maxFetches}, nil
+}
+
+func (d *DiskRawStore) Save(_ context.Context, f *models.RawFetch, body []byte) error {
+	logPath, err := d.logPath(f.Domain, f.Type)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	f.SHA256 = hex.EncodeToString(sum[:])
+	f.Size = len(body)
+	f.FirstFetchedAt = f.FetchedAt
+	f.Fetches = 1
+
+	// Bodies are immutable, so one already on disk never needs rewriting.
+	blob := d.blobPath(f.SHA256)
+	if _, err := os.Stat(blob); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+			return fmt.Errorf("raw SAVE failed: %w", err)
+		}
+		if err := fsutil.WriteFileAtomic(blob, body, 0o644); err != nil {
+			return fmt.Errorf("raw SAVE failed: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("raw SAVE failed: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	fetches, err := readFetchLog(logPath)
+	if err != nil {
+		return fmt.Errorf("raw SAVE failed: %w", err)
+	}
+	if n := len(fetches); n > 0 && sameResponse(fetches[n-1], f) {
+		f.FirstFetchedAt = fetches[n-1].FirstFetchedAt
+		f.Fetches = fetches[n-1].Fetches + 1
+		fetches[n-1] = f
+	} else {
+		fetches = append(fetches, f)
+	}
+	if d.maxFetches > 0 && len(fetches) > d.maxFetches {
+		fetches = fetches[len(fetches)-d.maxFetches:]
+	}
+
+	b, err := json.Marshal(fetches)
+	if err != nil {
+		return fmt.Errorf("raw SAVE failed: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		return fmt.Errorf("raw SAVE failed: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(logPath, b, 0o644); err != nil {
+		return fmt.Errorf("raw SAVE failed: %w", err)
+	}
+	return nil
+}
+
+func (d *DiskRawStore) Fetches(_ context.Context, domain, file string) ([]*models.RawFetch, error) {
+	logPath, err := d.logPath(domain, file)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	fetches, err := readFetchLog(logPath)
+	d.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("raw FETCHES failed: %w", err)
+	}
+	slices.Reverse(fetches)
+	return fetches, nil
+}
+
+func (d *DiskRawStore) Body(_ context.Context, sha string) ([]byte, error) {
+	if !validSHA256(sha) {
+		return nil, ErrBodyNotFound
+	}
+	b, err := os.ReadFile(d.blobPath(sha))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBodyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("raw BODY failed: %w", err)
+	}
+	return b, nil
+}
+
+func (d *DiskRawStore) blobPath(sha string) string {
+	return filepath.Join(d.dir, "blobs", sha[:2], sha)
+}
+
+func (d *DiskRawStore) logPath(domain, file string) (string, error) {
+	path, err := domainPath(filepath.Join(d.dir, "fetches"), domain, file)
+	if err != nil {
+		return "", err
+	}
+	return path + ".json", nil
+}
+
+// sameResponse reports whether next returned what last did, so it only
+// extends last's entry.
+func sameResponse(last, next *models.RawFetch) bool {
+	return last.SHA256 == next.SHA256 && last.URL == next.URL && last.StatusCode == next.StatusCode &&
+		slices.Equal(last.RedirectChain, next.RedirectChain)
+}
+
+func readFetchLog(path string) ([]*models.RawFetch, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []*models.RawFetch{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fetches []*models.RawFetch
+	if err := json.Unmarshal(b, &fetches); err != nil {
+		return nil, err
+	}
+	return fetches, nil
+}
+
+func validSHA256(sha string) bool {
+	if len(sha) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(sha)
+	return err == nil
+}
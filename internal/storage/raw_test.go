@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ads-txt-service/internal/models"
+)
+
+func rawFetch(domain, url string, at time.Time) *models.RawFetch {
+	return &models.RawFetch{
+		Domain:     domain,
+		Type:       models.FileAdsTxt,
+		URL:        url,
+		StatusCode: 200,
+		Header:     map[string][]string{"Content-Type": {"text/plain"}},
+		FetchedAt:  at,
+	}
+}
+
+func TestDiskRawStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	rs, err := NewDiskRawStore(dir, 2)
+	if err != nil {
+		t.Fatalf("NewDiskRawStore: %v", err)
+	}
+
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	body := []byte("google.com, pub-1, DIRECT\r\n# no trailing newline")
+	saves := []struct {
+		domain, url string
+		body        []byte
+	}{
+		{"example.com", "https://example.com/ads.txt", []byte("old\n")},
+		{"example.com", "https://example.com/ads.txt", body},
+		{"example.com", "https://example.com/ads.txt", body},
+		{"example.com", "https://www.example.com/ads.txt", body},
+		{"other.com", "https://other.com/ads.txt", body},
+	}
+	for i, sv := range saves {
+		if err := rs.Save(ctx, rawFetch(sv.domain, sv.url, t0.Add(time.Duration(i)*time.Hour)), sv.body); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	fetches, err := rs.Fetches(ctx, "example.com", models.FileAdsTxt)
+	if err != nil {
+		t.Fatalf("Fetches: %v", err)
+	}
+	// Identical consecutive fetches share an entry; a new URL starts one,
+	// and only the two newest entries are kept.
+	if len(fetches) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", fetches)
+	}
+	if fetches[0].URL != "https://www.example.com/ads.txt" || fetches[0].Fetches != 1 {
+		t.Errorf("unexpected newest entry: %+v", fetches[0])
+	}
+	if f := fetches[1]; f.Fetches != 2 || !f.FirstFetchedAt.Equal(t0.Add(time.Hour)) || !f.FetchedAt.Equal(t0.Add(2*time.Hour)) || f.Size != len(body) {
+		t.Errorf("expected the repeated fetch to be collapsed: %+v", f)
+	}
+
+	got, err := rs.Body(ctx, fetches[0].SHA256)
+	if err != nil {
+		t.Fatalf("Body: %v", err)
+	}
+	if string(got) != string(body) {
+		t.Errorf("body not returned byte for byte: %q", got)
+	}
+
+	// Both domains and the older body: two blobs in total.
+	blobs, _ := filepath.Glob(filepath.Join(dir, "blobs", "*", "*"))
+	if len(blobs) != 2 {
+		t.Errorf("expected identical bodies to be stored once, found %d blobs", len(blobs))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fetches", models.FileAdsTxt, "other.com.json")); err != nil {
+		t.Errorf("expected a fetch log for other.com: %v", err)
+	}
+
+	if _, err := rs.Body(ctx, "../../etc/passwd"); !errors.Is(err, ErrBodyNotFound) {
+		t.Errorf("expected ErrBodyNotFound for an invalid hash, got %v", err)
+	}
+	if _, err := rs.Body(ctx, "0000000000000000000000000000000000000000000000000000000000000000"); !errors.Is(err, ErrBodyNotFound) {
+		t.Errorf("expected ErrBodyNotFound for an unknown hash, got %v", err)
+	}
+	if fetches, err := rs.Fetches(ctx, "unknown.com", models.FileAdsTxt); err != nil || len(fetches) != 0 {
+		t.Errorf("expected no fetches for an unknown domain, got %v, %v", fetches, err)
+	}
+}
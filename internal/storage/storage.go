@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ads-txt-service/internal/models"
+)
+
+// ErrNotFound is returned by Get when no snapshot has the requested ID.
+var ErrNotFound = errors.New("snapshot not found")
+
+// idLayout formats fetch times into snapshot IDs. It is fixed width, so IDs
+// sort lexically in fetch order.
+const idLayout = "20060102T150405.000000000Z"
+
+// SnapshotStore keeps the history of fetched files per domain and type.
+type SnapshotStore interface {
+	// Save stores snap, assigning its ID from FetchedAt when empty.
+	Save(ctx context.Context, snap *models.Snapshot) error
+	// List returns the metadata of the stored snapshots, newest first.
+	List(ctx context.Context, domain, file string) ([]*models.SnapshotMeta, error)
+	// Get returns a single snapshot or ErrNotFound.
+	Get(ctx context.Context, domain, file, id string) (*models.Snapshot, error)
+}
+
+// NewID derives a snapshot ID from its fetch time.
+func NewID(fetchedAt time.Time) string {
+	return fetchedAt.UTC().Format(idLayout)
+}
+
+func validID(id string) bool {
+	_, err := time.Parse(idLayout, id)
+	return err == nil
+}
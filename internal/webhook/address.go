@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// ErrDisallowedAddress is returned for webhook hosts on loopback,
+// link-local, private or otherwise internal addresses. Webhook URLs come
+// from API clients, so without this check they could make the service
+// call its own network, cloud metadata endpoints included.
+var ErrDisallowedAddress = errors.New("address not allowed for webhooks")
+
+// internalPrefixes are non-public ranges that netip.Addr has no predicate
+// for.
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// AllowedAddr reports whether webhooks may be delivered to ip.
+func AllowedAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, p := range internalPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckHost rejects a webhook host that is, or resolves to, a disallowed
+// address. A host that does not resolve is let through: it may only exist
+// later, and every delivery is checked again when it connects.
+func CheckHost(ctx context.Context, host string) error {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !AllowedAddr(ip) {
+			return fmt.Errorf("%w: %s", ErrDisallowedAddress, host)
+		}
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if !AllowedAddr(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrDisallowedAddress, host, ip)
+		}
+	}
+	return nil
+}
+
+// dialControl refuses connections to disallowed addresses. It runs after
+// name resolution, for every connection including those of redirects, so a
+// host cannot pass CheckHost and later resolve somewhere internal.
+func dialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !AllowedAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrDisallowedAddress, ip)
+	}
+	return nil
+}
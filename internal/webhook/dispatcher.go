@@ -0,0 +1,328 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256 of
+// the body keyed with the webhook's secret, prefixed with "sha256=".
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// workers bounds how many deliveries are attempted at once.
+	workers = 4
+	// queueSize bounds deliveries waiting for a worker. When it is full new
+	// deliveries go straight to the dead-letter log.
+	queueSize = 1000
+	// maxBackoff caps the wait between attempts, however many are allowed.
+	maxBackoff = time.Hour
+)
+
+type delivery struct {
+	ID        string
+	Webhook   *models.Webhook
+	Event     string
+	Body      []byte
+	Attempts  int
+	LastError string
+}
+
+// deadLetter is a line of the dead-letter log.
+type deadLetter struct {
+	DeliveryID string          `json:"delivery_id"`
+	WebhookID  string          `json:"webhook_id"`
+	URL        string          `json:"url"`
+	Event      string          `json:"event"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error"`
+	FailedAt   time.Time       `json:"failed_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Dispatcher delivers change events to the registered webhooks. A failed
+// delivery is retried with exponential backoff, starting at backoff and
+// capped at maxBackoff, up to maxAttempts attempts; after that it is
+// appended to the dead-letter log as a line of JSON. Deliveries left
+// undelivered when the dispatcher stops are written there too, so that no
+// failed delivery goes unrecorded.
+type Dispatcher struct {
+	reg         *Registry
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	deadLetter  string
+	log         *logger.Logger
+
+	queue chan *delivery
+
+	// mu guards retries, the deliveries waiting for their next attempt, and
+	// stopped, set once Run has returned.
+	mu      sync.Mutex
+	retries map[*delivery]*time.Timer
+	stopped bool
+
+	// dlMu serialises appends to the dead-letter log.
+	dlMu sync.Mutex
+}
+
+// NewDispatcher returns a dispatcher for the webhooks in reg. Unless
+// allowPrivate is set, deliveries to loopback, link-local and private
+// addresses are refused, and no proxy is used so that the check applies to
+// the webhook's own address.
+func NewDispatcher(reg *Registry, timeout time.Duration, maxAttempts int, backoff time.Duration, deadLetterPath string, allowPrivate bool, log *logger.Logger) *Dispatcher {
+	client := &http.Client{Timeout: timeout}
+	if !allowPrivate {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{Timeout: timeout, Control: dialControl}).DialContext
+		client.Transport = transport
+	}
+	return &Dispatcher{
+		reg:         reg,
+		client:      client,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		deadLetter:  deadLetterPath,
+		log:         log,
+		queue:       make(chan *delivery, queueSize),
+		retries:     make(map[*delivery]*time.Timer),
+	}
+}
+
+// Notify queues a delivery of ev to every webhook subscribed to its domain.
+// It never blocks.
+func (d *Dispatcher) Notify(ev *models.ChangeEvent) {
+	hooks := d.reg.matching(ev.Domain)
+	if len(hooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		d.log.Errorw("Failed to encode webhook payload", zap.Error(err), "domain", ev.Domain)
+		return
+	}
+	for _, h := range hooks {
+		d.enqueue(&delivery{ID: randomHex(8), Webhook: h, Event: ev.Event, Body: body})
+	}
+}
+
+// Run delivers queued events until ctx is cancelled. Deliveries still
+// queued, in flight or waiting for a retry at that point, and any notified
+// later, go to the dead-letter log.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case dl := <-d.queue:
+					d.attempt(ctx, dl)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	d.stop()
+}
+
+// stop records every delivery that Run left undelivered. From then on
+// enqueue records deliveries instead of queueing them.
+func (d *Dispatcher) stop() {
+	d.mu.Lock()
+	d.stopped = true
+	var pending []*delivery
+	for dl, t := range d.retries {
+		// A timer that already fired finds the dispatcher stopped and
+		// records its delivery itself.
+		if t.Stop() {
+			pending = append(pending, dl)
+		}
+	}
+	clear(d.retries)
+	d.mu.Unlock()
+
+	for {
+		select {
+		case dl := <-d.queue:
+			pending = append(pending, dl)
+		default:
+			if len(pending) > 0 {
+				d.log.Warnw("Webhook dispatcher stopped, recording undelivered deliveries", "deliveries", len(pending))
+			}
+			for _, dl := range pending {
+				d.abandon(dl, "dispatcher stopped before delivery")
+			}
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, dl *delivery) {
+	if !d.reg.exists(dl.Webhook.ID) {
+		d.log.Infow("Webhook deleted, dropping delivery", "webhook", dl.Webhook.ID, "delivery", dl.ID)
+		return
+	}
+	dl.Attempts++
+	err := d.send(ctx, dl)
+	if err == nil {
+		d.log.Infow("Webhook delivered", "webhook", dl.Webhook.ID, "delivery", dl.ID, "attempts", dl.Attempts)
+		return
+	}
+	dl.LastError = err.Error()
+	if ctx.Err() != nil {
+		d.abandon(dl, "dispatcher stopped during delivery")
+		return
+	}
+	if dl.Attempts >= d.maxAttempts {
+		d.log.Warnw("Webhook delivery failed, giving up", zap.Error(err), "webhook", dl.Webhook.ID, "delivery", dl.ID)
+		d.writeDeadLetter(dl)
+		return
+	}
+
+	wait := d.retryDelay(dl.Attempts)
+	d.log.Infow("Webhook delivery failed, retrying", zap.Error(err), "webhook", dl.Webhook.ID, "delivery", dl.ID, "retry_in", wait)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		d.abandon(dl, "dispatcher stopped before retry")
+		return
+	}
+	d.retries[dl] = time.AfterFunc(wait, func() {
+		d.mu.Lock()
+		delete(d.retries, dl)
+		d.mu.Unlock()
+		d.enqueue(dl)
+	})
+}
+
+// retryDelay returns the wait after the given number of failed attempts:
+// backoff doubled for each attempt after the first, up to maxBackoff.
+func (d *Dispatcher) retryDelay(attempts int) time.Duration {
+	wait := d.backoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+func (d *Dispatcher) send(ctx context.Context, dl *delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.Webhook.URL, bytes.NewReader(dl.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, dl.Event)
+	req.Header.Set(HeaderDelivery, dl.ID)
+	req.Header.Set(HeaderSignature, Sign(dl.Webhook.Secret, dl.Body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (d *Dispatcher) enqueue(dl *delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		d.abandon(dl, "dispatcher stopped before delivery")
+		return
+	}
+	select {
+	case d.queue <- dl:
+	default:
+		d.log.Warnw("Webhook queue full", "webhook", dl.Webhook.ID, "delivery", dl.ID)
+		d.abandon(dl, "delivery queue full")
+	}
+}
+
+// abandon records a delivery that will not be attempted again, unless its
+// webhook has been deleted in the meantime. The error of its last attempt,
+// if any, is kept after reason.
+func (d *Dispatcher) abandon(dl *delivery, reason string) {
+	if !d.reg.exists(dl.Webhook.ID) {
+		return
+	}
+	if dl.LastError != "" {
+		reason += ", last error: " + dl.LastError
+	}
+	dl.LastError = reason
+	d.writeDeadLetter(dl)
+}
+
+func (d *Dispatcher) writeDeadLetter(dl *delivery) {
+	line, err := json.Marshal(&deadLetter{
+		DeliveryID: dl.ID,
+		WebhookID:  dl.Webhook.ID,
+		URL:        dl.Webhook.URL,
+		Event:      dl.Event,
+		Attempts:   dl.Attempts,
+		LastError:  dl.LastError,
+		FailedAt:   time.Now().UTC(),
+		Payload:    dl.Body,
+	})
+	if err != nil {
+		d.log.Errorw("Failed to encode dead letter", zap.Error(err), "delivery", dl.ID)
+		return
+	}
+
+	d.dlMu.Lock()
+	defer d.dlMu.Unlock()
+	if err := appendLine(d.deadLetter, line); err != nil {
+		d.log.Errorw("Failed to write dead letter", zap.Error(err), "delivery", dl.ID)
+	}
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func appendLine(path string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
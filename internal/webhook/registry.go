@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ads-txt-service/internal/fsutil"
+	"ads-txt-service/internal/models"
+)
+
+// Registry keeps the registered webhooks, persisted as a single JSON file
+// that is rewritten atomically on every change.
+type Registry struct {
+	path string
+
+	mu    sync.RWMutex
+	hooks map[string]*models.Webhook
+}
+
+// NewRegistry loads the webhooks from path, starting empty if it does not
+// exist.
+func NewRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path, hooks: make(map[string]*models.Webhook)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks file: %w", err)
+	}
+	var list []*models.Webhook
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode webhooks file: %w", err)
+	}
+	for _, h := range list {
+		r.hooks[h.ID] = h
+	}
+	return r, nil
+}
+
+// Add registers h, assigning its ID, creation time and, when empty, a random
+// secret.
+func (r *Registry) Add(h *models.Webhook) error {
+	h.ID = randomHex(8)
+	if h.Secret == "" {
+		h.Secret = randomHex(32)
+	}
+	h.CreatedAt = time.Now().UTC()
+	cp := clone(h)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks[cp.ID] = cp
+	if err := r.saveLocked(); err != nil {
+		delete(r.hooks, cp.ID)
+		return err
+	}
+	return nil
+}
+
+// List returns the webhooks, secrets included, oldest first.
+func (r *Registry) List() []*models.Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.listLocked()
+}
+
+// Delete removes a webhook and reports whether it existed.
+func (r *Registry) Delete(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.hooks[id]
+	if !ok {
+		return false, nil
+	}
+	delete(r.hooks, id)
+	if err := r.saveLocked(); err != nil {
+		r.hooks[id] = old
+		return false, err
+	}
+	return true, nil
+}
+
+// exists reports whether the webhook with the given ID is still registered.
+func (r *Registry) exists(id string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.hooks[id]
+	return ok
+}
+
+// matching returns the webhooks subscribed to a domain.
+func (r *Registry) matching(domain string) []*models.Webhook {
+	var out []*models.Webhook
+	for _, h := range r.List() {
+		if len(h.Domains) == 0 || slices.Contains(h.Domains, domain) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+func (r *Registry) listLocked() []*models.Webhook {
+	out := make([]*models.Webhook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		out = append(out, clone(h))
+	}
+	slices.SortFunc(out, func(a, b *models.Webhook) int {
+		if n := a.CreatedAt.Compare(b.CreatedAt); n != 0 {
+			return n
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
+}
+
+func (r *Registry) saveLocked() error {
+	b, err := json.MarshalIndent(r.listLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode webhooks: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to save webhooks: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(r.path, b, 0o600); err != nil {
+		return fmt.Errorf("failed to save webhooks: %w", err)
+	}
+	return nil
+}
+
+func clone(h *models.Webhook) *models.Webhook {
+	cp := *h
+	cp.Domains = slices.Clone(h.Domains)
+	return &cp
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
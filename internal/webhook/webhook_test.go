@@ -0,0 +1,324 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ads-txt-service/internal/logger"
+	"ads-txt-service/internal/models"
+)
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	reg, err := NewRegistry(path)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	h := &models.Webhook{URL: "https://example.com/hook", Domains: []string{"msn.com"}}
+	if err := reg.Add(h); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if h.ID == "" || h.Secret == "" {
+		t.Fatalf("expected ID and secret to be assigned: %+v", h)
+	}
+	reg.Add(&models.Webhook{URL: "https://example.com/all"})
+
+	reg, err = NewRegistry(path)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	if n := len(reg.List()); n != 2 {
+		t.Fatalf("expected 2 webhooks after reload, got %d", n)
+	}
+	if n := len(reg.matching("msn.com")); n != 2 {
+		t.Errorf("expected both webhooks to match msn.com, got %d", n)
+	}
+	if n := len(reg.matching("cnn.com")); n != 1 {
+		t.Errorf("expected only the unfiltered webhook to match cnn.com, got %d", n)
+	}
+	if ok, err := reg.Delete(h.ID); !ok || err != nil {
+		t.Errorf("Delete = %v, %v", ok, err)
+	}
+	if ok, _ := reg.Delete(h.ID); ok {
+		t.Error("expected deleting twice to report false")
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	logger.Init("info")
+	dir := t.TempDir()
+
+	var calls atomic.Int32
+	received := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt to exercise the retry.
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- b
+	}))
+	defer ok.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	reg, _ := NewRegistry(filepath.Join(dir, "webhooks.json"))
+	good := &models.Webhook{URL: ok.URL, Secret: "s3cret"}
+	reg.Add(good)
+	reg.Add(&models.Webhook{URL: broken.URL, Domains: []string{"msn.com"}})
+	reg.Add(&models.Webhook{URL: broken.URL, Domains: []string{"other.com"}})
+
+	deadLetters := filepath.Join(dir, "dead.jsonl")
+	d := NewDispatcher(reg, time.Second, 3, 5*time.Millisecond, deadLetters, true, logger.L())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Notify(&models.ChangeEvent{
+		Event:  models.EventAdsChanged,
+		Domain: "msn.com",
+		Type:   models.FileAdsTxt,
+		RecordDiff: models.RecordDiff{
+			Removed: []*models.AdsRecord{{AdSystem: "google.com", PublisherID: "pub-1", Relationship: models.RelationshipDirect}},
+		},
+	})
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		if got, want := r.Header.Get(HeaderSignature), Sign("s3cret", body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if r.Header.Get(HeaderEvent) != models.EventAdsChanged {
+			t.Errorf("unexpected event header %q", r.Header.Get(HeaderEvent))
+		}
+		var ev models.ChangeEvent
+		if err := json.Unmarshal(body, &ev); err != nil || len(ev.Removed) != 1 {
+			t.Errorf("unexpected payload %s: %v", body, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	// The broken endpoint ends up in the dead-letter log after 3 attempts;
+	// the webhook filtered to other.com is never called.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b, _ := os.ReadFile(deadLetters)
+		if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(b) > 0 {
+			if len(lines) != 1 {
+				t.Fatalf("expected one dead letter, got %d", len(lines))
+			}
+			var dl deadLetter
+			if err := json.Unmarshal([]byte(lines[0]), &dl); err != nil {
+				t.Fatalf("failed to decode dead letter: %v", err)
+			}
+			if dl.Attempts != 3 || dl.URL != broken.URL || !strings.Contains(dl.LastError, "502") {
+				t.Errorf("unexpected dead letter: %+v", dl)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected a dead letter for the broken endpoint")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDispatcher_RetryDelay(t *testing.T) {
+	d := NewDispatcher(nil, time.Second, 100, 2*time.Second, "", true, logger.L())
+	for attempts, want := range map[int]time.Duration{
+		1:  2 * time.Second,
+		2:  4 * time.Second,
+		5:  32 * time.Second,
+		12: maxBackoff,
+		40: maxBackoff,
+		99: maxBackoff,
+	} {
+		if got := d.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestAllowedAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"0.0.0.0":              false,
+		"100.64.0.1":           false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	} {
+		if got := AllowedAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("AllowedAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestDispatcher_RefusesPrivateAddresses(t *testing.T) {
+	logger.Init("info")
+	dir := t.TempDir()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	// Registered directly, as if the host had resolved publicly when the
+	// webhook was added.
+	reg, _ := NewRegistry(filepath.Join(dir, "webhooks.json"))
+	reg.Add(&models.Webhook{URL: srv.URL})
+	deadLetters := filepath.Join(dir, "dead.jsonl")
+	d := NewDispatcher(reg, time.Second, 1, time.Millisecond, deadLetters, false, logger.L())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Notify(&models.ChangeEvent{Event: models.EventAdsChanged, Domain: "msn.com", Type: models.FileAdsTxt})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b, _ := os.ReadFile(deadLetters)
+		if len(b) > 0 {
+			if !strings.Contains(string(b), ErrDisallowedAddress.Error()) {
+				t.Errorf("expected the delivery to be refused, got %s", b)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected a dead letter for the loopback webhook")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no request to reach the loopback server, got %d", calls.Load())
+	}
+}
+
+func TestDispatcher_RecordsUndeliveredOnStop(t *testing.T) {
+	logger.Init("info")
+	dir := t.TempDir()
+
+	attempted := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		attempted <- struct{}{}
+	}))
+	defer srv.Close()
+
+	reg, _ := NewRegistry(filepath.Join(dir, "webhooks.json"))
+	reg.Add(&models.Webhook{URL: srv.URL})
+	deadLetters := filepath.Join(dir, "dead.jsonl")
+	// The retry is an hour away when the dispatcher stops.
+	d := NewDispatcher(reg, time.Second, 5, time.Hour, deadLetters, true, logger.L())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	d.Notify(&models.ChangeEvent{Event: models.EventAdsChanged, Domain: "msn.com", Type: models.FileAdsTxt})
+	select {
+	case <-attempted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not attempted")
+	}
+	// Give the worker time to schedule the retry.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	// Notified after the dispatcher stopped.
+	d.Notify(&models.ChangeEvent{Event: models.EventAdsChanged, Domain: "cnn.com", Type: models.FileAdsTxt})
+
+	b, err := os.ReadFile(deadLetters)
+	if err != nil {
+		t.Fatalf("expected a dead-letter log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two dead letters, got %s", b)
+	}
+	var dl deadLetter
+	if err := json.Unmarshal([]byte(lines[0]), &dl); err != nil {
+		t.Fatalf("failed to decode dead letter: %v", err)
+	}
+	if dl.Attempts != 1 || !strings.Contains(dl.LastError, "stopped") || !strings.Contains(dl.LastError, "503") {
+		t.Errorf("unexpected dead letter for the pending retry: %+v", dl)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &dl); err != nil {
+		t.Fatalf("failed to decode dead letter: %v", err)
+	}
+	if dl.Attempts != 0 || !strings.Contains(dl.LastError, "stopped") {
+		t.Errorf("unexpected dead letter for the late notification: %+v", dl)
+	}
+}
+
+func TestDispatcher_DropsRetriesOfDeletedWebhooks(t *testing.T) {
+	logger.Init("info")
+	dir := t.TempDir()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	reg, _ := NewRegistry(filepath.Join(dir, "webhooks.json"))
+	h := &models.Webhook{URL: srv.URL}
+	reg.Add(h)
+	deadLetters := filepath.Join(dir, "dead.jsonl")
+	d := NewDispatcher(reg, time.Second, 3, 50*time.Millisecond, deadLetters, true, logger.L())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	d.Notify(&models.ChangeEvent{Event: models.EventAdsChanged, Domain: "msn.com", Type: models.FileAdsTxt})
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("webhook was not attempted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	reg.Delete(h.ID)
+
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	<-done
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected no retries after the webhook was deleted, got %d attempts", n)
+	}
+	if _, err := os.Stat(deadLetters); err == nil {
+		t.Error("expected no dead letter for a deleted webhook")
+	}
+}